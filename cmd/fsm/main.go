@@ -0,0 +1,165 @@
+// Command fsm works with machine definition files, see fsm.Definition for
+// their format.
+//
+// Usage:
+//
+//	fsm validate <definition>
+//	fsm visualize [--format graphviz] [--current state] [--direction LR] [--output file] <definition>
+//	fsm simulate --events a,b,c [--initial state] <definition>
+//	fsm paths --to state [--from state] [--limit 10] <definition>
+//	fsm repl [--initial state] <definition>
+//
+// The flags of a command come before the definition file. Simulate exits with
+// a non-zero code on the first event that fails, so it can be used as a check.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+// command is a subcommand of fsm.
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"validate":  {"validate <definition>", withoutInput(runValidate)},
+		"visualize": {"visualize [--format graphviz] [--current state] [--direction LR] [--output file] <definition>", withoutInput(runVisualize)},
+		"simulate":  {"simulate --events a,b,c [--initial state] <definition>", withoutInput(runSimulate)},
+		"paths":     {"paths --to state [--from state] [--limit 10] <definition>", withoutInput(runPaths)},
+		"repl":      {"repl [--initial state] <definition>", runREPL},
+	}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+
+		return exitUsage
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n", args[0])
+		usage(stderr)
+
+		return exitUsage
+	}
+
+	return cmd.run(args[1:], stdin, stdout, stderr)
+}
+
+// withoutInput adapts a command which does not read the standard input.
+func withoutInput(run func(args []string, stdout, stderr io.Writer) int) func([]string, io.Reader, io.Writer, io.Writer) int {
+	return func(args []string, _ io.Reader, stdout, stderr io.Writer) int {
+		return run(args, stdout, stderr)
+	}
+}
+
+func usage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "usage:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  fsm %s\n", commands[name].usage)
+	}
+}
+
+// newFlagSet returns the flag set of the command name writing its errors to stderr.
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: fsm %s\n", commands[name].usage)
+		flags.PrintDefaults()
+	}
+
+	return flags
+}
+
+// parseArgs parses the flags of the command and loads the definition given
+// as its only argument.
+func parseArgs(flags *flag.FlagSet, args []string, stderr io.Writer) (*fsm.Definition, int) {
+	if err := flags.Parse(args); err != nil {
+		return nil, exitUsage
+	}
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+
+		return nil, exitUsage
+	}
+
+	definition, err := fsm.LoadDefinition(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return nil, exitFailure
+	}
+
+	return definition, exitOK
+}
+
+// newMachine returns the machine of the definition with a no-op callback
+// for each of its declared callbacks.
+func newMachine(definition *fsm.Definition) *fsm.Machine {
+	callbacks := make(map[string]fsm.Callback, len(definition.Callbacks))
+	for _, name := range definition.Callbacks {
+		callbacks[name] = func(*fsm.Transition) {}
+	}
+
+	return definition.Machine(callbacks)
+}
+
+// stateOrInitial returns state, or the initial state of the definition if
+// state is empty.
+func stateOrInitial(definition *fsm.Definition, state, flag string, stderr io.Writer) (string, bool) {
+	if state == "" {
+		state = definition.Initial
+	}
+
+	if state == "" {
+		fmt.Fprintf(stderr, "no initial state in the definition, --%s is required\n", flag)
+
+		return "", false
+	}
+
+	return state, true
+}
+
+// splitList splits a comma separated list, ignoring empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
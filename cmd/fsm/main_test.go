@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+const doorDefinition = `{
+	"name": "door",
+	"initial": "closed",
+	"transitions": [
+		{"name": "open", "sources": ["closed"], "destination": "open"},
+		{"name": "close", "sources": ["open"], "destination": "closed"},
+		{"name": "part-open", "sources": ["closed"], "destination": "ajar"},
+		{"name": "open", "sources": ["ajar"], "destination": "open"},
+		{"name": "close", "sources": ["ajar"], "destination": "closed"}
+	],
+	"callbacks": ["enter_open"]
+}`
+
+func writeDefinition(t *testing.T, definition string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "definition.json")
+	if err := os.WriteFile(path, []byte(definition), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func runCommand(args ...string) (int, string, string) {
+	return runCommandWithInput("", args...)
+}
+
+func runCommandWithInput(input string, args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, strings.NewReader(input), &stdout, &stderr)
+
+	return code, stdout.String(), stderr.String()
+}
+
+func TestValidate(t *testing.T) {
+	code, stdout, _ := runCommand("validate", writeDefinition(t, doorDefinition))
+	if code != exitOK || stdout != "ok\n" {
+		t.Errorf("expected a valid definition, got %d and %q", code, stdout)
+	}
+
+	invalid := strings.Replace(doorDefinition, `"enter_open"`, `"enter_opne"`, 1)
+	code, stdout, _ = runCommand("validate", writeDefinition(t, invalid))
+	if code != exitFailure || stdout != "invalid definition: callback enter_opne does not refer to any event or state\n" {
+		t.Errorf("expected an invalid definition, got %d and %q", code, stdout)
+	}
+}
+
+func TestVisualize(t *testing.T) {
+	code, stdout, _ := runCommand("visualize", "--format", "mermaid", "--current", "open", writeDefinition(t, doorDefinition))
+	if code != exitOK || !strings.HasPrefix(stdout, "stateDiagram-v2\n") || !strings.Contains(stdout, "[*] --> open") {
+		t.Errorf("expected a mermaid diagram, got %d and \n%s", code, stdout)
+	}
+
+	code, _, stderr := runCommand("visualize", "--format", "unknown", writeDefinition(t, doorDefinition))
+	if code != exitUsage || stderr != "unknown VisualizeType: unknown\n" {
+		t.Errorf("expected an unknown format to be rejected, got %d and %q", code, stderr)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	code, stdout, _ := runCommand("simulate", "--events", "part-open,close,open", writeDefinition(t, doorDefinition))
+
+	wanted := `part-open: closed -> ajar
+close: ajar -> closed
+open: closed -> open
+final state: open
+`
+	if code != exitOK || stdout != wanted {
+		t.Errorf("wanted \n%s\nand got %d and \n%s", wanted, code, stdout)
+	}
+
+	code, stdout, _ = runCommand("simulate", "--events", "part-open,close,close,open", writeDefinition(t, doorDefinition))
+
+	wanted = `part-open: closed -> ajar
+close: ajar -> closed
+close: error: event close inappropriate in current state closed
+final state: closed
+`
+	if code != exitFailure || stdout != wanted {
+		t.Errorf("wanted \n%s\nand got %d and \n%s", wanted, code, stdout)
+	}
+}
+
+func TestPaths(t *testing.T) {
+	code, stdout, _ := runCommand("paths", "--to", "open", writeDefinition(t, doorDefinition))
+
+	wanted := `closed -open-> open
+closed -part-open-> ajar -open-> open
+`
+	if code != exitOK || stdout != wanted {
+		t.Errorf("wanted \n%s\nand got %d and \n%s", wanted, code, stdout)
+	}
+
+	code, stdout, _ = runCommand("paths", "--to", "open", "--limit", "1", writeDefinition(t, doorDefinition))
+	if code != exitOK || stdout != "closed -open-> open\n" {
+		t.Errorf("expected the shortest path only, got %d and %q", code, stdout)
+	}
+
+	code, stdout, _ = runCommand("paths", "--from", "open", "--to", "nowhere", writeDefinition(t, doorDefinition))
+	if code != exitFailure || stdout != "no path from open to nowhere\n" {
+		t.Errorf("expected no path, got %d and %q", code, stdout)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	code, _, stderr := runCommand("explode")
+	if code != exitUsage || !strings.HasPrefix(stderr, "unknown command \"explode\"\nusage:\n") {
+		t.Errorf("expected the usage, got %d and %q", code, stderr)
+	}
+}
+
+// completeDefinition returns a definition of states s0 to s12 where every
+// state leads to every other one, so there are more than 12! simple paths
+// from s0 to s12.
+func completeDefinition() *fsm.Definition {
+	definition := &fsm.Definition{}
+	for i := 0; i <= 12; i++ {
+		var sources []string
+		for j := 0; j <= 12; j++ {
+			if j != i {
+				sources = append(sources, fmt.Sprintf("s%d", j))
+			}
+		}
+		definition.Transitions = append(definition.Transitions, fsm.TransitionDesc{
+			Name: fmt.Sprintf("to-s%d", i), Sources: sources, Destination: fmt.Sprintf("s%d", i),
+		})
+	}
+
+	return definition
+}
+
+func TestFindPathsStopsAtLimit(t *testing.T) {
+	definition := completeDefinition()
+
+	done := make(chan [][]step, 1)
+	go func() {
+		done <- findPaths(definition, "s0", "s12", 3)
+	}()
+
+	select {
+	case paths := <-done:
+		if len(paths) != 3 || len(paths[0]) != 1 || len(paths[1]) != 2 {
+			t.Errorf("expected the 3 shortest paths, got %v", paths)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the search to stop once the limit is reached")
+	}
+}
+
+func TestFindPathsUnreachable(t *testing.T) {
+	definition := completeDefinition()
+	definition.Transitions = append(definition.Transitions,
+		fsm.TransitionDesc{Name: "swim", Sources: []string{"island1"}, Destination: "island2"},
+		fsm.TransitionDesc{Name: "leave", Sources: []string{"island2"}, Destination: "s0"},
+	)
+
+	done := make(chan [][]step, 1)
+	go func() {
+		done <- findPaths(definition, "s0", "island2", 3)
+	}()
+
+	select {
+	case paths := <-done:
+		if len(paths) != 0 {
+			t.Errorf("expected no path, got %v", paths)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the search to stop when the destination is unreachable")
+	}
+}
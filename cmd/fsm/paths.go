@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// step is a transition taken by a path.
+type step struct {
+	event, destination string
+}
+
+// runPaths prints the paths between two states which do not go through a
+// state twice, the shortest first.
+func runPaths(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("paths", stderr)
+	from := flags.String("from", "", "state to start from, the initial state of the definition by default")
+	to := flags.String("to", "", "state to reach")
+	limit := flags.Int("limit", 10, "maximum number of paths to print, all of them if lower than one")
+
+	definition, code := parseArgs(flags, args, stderr)
+	if definition == nil {
+		return code
+	}
+
+	source, ok := stateOrInitial(definition, *from, "from", stderr)
+	if !ok {
+		return exitUsage
+	}
+	if *to == "" {
+		flags.Usage()
+
+		return exitUsage
+	}
+
+	paths := findPaths(definition, source, *to, *limit)
+	if len(paths) == 0 {
+		fmt.Fprintf(stdout, "no path from %s to %s\n", source, *to)
+
+		return exitFailure
+	}
+
+	for _, path := range paths {
+		var builder strings.Builder
+		builder.WriteString(source)
+		for _, s := range path {
+			builder.WriteString(fmt.Sprintf(" -%s-> %s", s.event, s.destination))
+		}
+
+		fmt.Fprintln(stdout, builder.String())
+	}
+
+	return exitOK
+}
+
+// findPaths returns up to limit paths from source to destination which do not
+// go through a state twice, sorted by length and then by events, or all of
+// them if limit is lower than one.
+//
+// The paths are searched one length at a time, so that the search stops as
+// soon as limit paths are found instead of enumerating every path first. The
+// states too far from the destination to reach it within the length are not
+// walked through.
+func findPaths(definition *fsm.Definition, source, destination string, limit int) [][]step {
+	next := make(map[string][]step)
+	previous := make(map[string][]string)
+	for _, transition := range definition.Transitions {
+		for _, src := range transition.Sources {
+			next[src] = append(next[src], step{transition.Name, transition.Destination})
+			previous[transition.Destination] = append(previous[transition.Destination], src)
+		}
+	}
+
+	// distance is the length of the shortest path from each state able to
+	// reach the destination.
+	distance := map[string]int{destination: 0}
+	for queue := []string{destination}; len(queue) > 0; queue = queue[1:] {
+		for _, src := range previous[queue[0]] {
+			if _, ok := distance[src]; !ok {
+				distance[src] = distance[queue[0]] + 1
+				queue = append(queue, src)
+			}
+		}
+	}
+
+	if _, ok := distance[source]; !ok {
+		return nil
+	}
+
+	full := func(paths [][]step) bool {
+		return limit > 0 && len(paths) >= limit
+	}
+
+	var paths [][]step
+	for length := 1; length <= len(definition.States()) && !full(paths); length++ {
+		var found [][]step
+		visited := map[string]bool{source: true}
+
+		var walk func(state string, path []step)
+		walk = func(state string, path []step) {
+			for _, s := range next[state] {
+				if full(found) {
+					return
+				}
+
+				if len(path)+1 == length {
+					if s.destination == destination {
+						found = append(found, append(append([]step(nil), path...), s))
+					}
+
+					continue
+				}
+
+				remaining, ok := distance[s.destination]
+				if !ok || len(path)+1+remaining > length || s.destination == destination || visited[s.destination] {
+					continue
+				}
+
+				visited[s.destination] = true
+				walk(s.destination, append(path, s))
+				visited[s.destination] = false
+			}
+		}
+		walk(source, nil)
+
+		sort.Slice(found, func(i, j int) bool {
+			return fmt.Sprint(found[i]) < fmt.Sprint(found[j])
+		})
+
+		if limit > 0 && len(found) > limit-len(paths) {
+			found = found[:limit-len(paths)]
+		}
+		paths = append(paths, found...)
+	}
+
+	return paths
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+const replHelp = `commands:
+  state                    print the current state
+  available                print the events available in the current state
+  fire <event> [args...]   fire an event, the args are JSON values or strings
+  set <key> <value>        set a metadata, the value is a JSON value or a string
+  get <key>                print a metadata
+  meta                     print the metadata set in this session
+  undo                     undo the last event or metadata change
+  history                  print the events and metadata changes
+  diagram [format]         print the machine in a format of visualize, ascii by default
+  help                     print this help
+  quit                     leave the shell
+`
+
+// replStep is a change made in the shell: an event fired or a metadata set.
+type replStep struct {
+	event string
+	args  []interface{}
+	key   string
+	value interface{}
+}
+
+func (s replStep) String() string {
+	if s.event == "" {
+		return fmt.Sprintf("set %s %v", s.key, s.value)
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("fire %s %s", s.event, strings.Trim(fmt.Sprint(s.args), "[]")))
+}
+
+// repl is an interactive shell on an instance of a definition.
+type repl struct {
+	definition *fsm.Definition
+	machine    *fsm.Machine
+	initial    string
+	instance   *fsm.Instance
+	steps      []replStep
+	keys       map[string]bool
+	stdout     io.Writer
+}
+
+// runREPL starts an interactive shell on an instance of the definition,
+// reading commands from stdin.
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := newFlagSet("repl", stderr)
+	initial := flags.String("initial", "", "state to start in, the initial state of the definition by default")
+
+	definition, code := parseArgs(flags, args, stderr)
+	if definition == nil {
+		return code
+	}
+
+	state, ok := stateOrInitial(definition, *initial, "initial", stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	r := &repl{definition: definition, machine: newMachine(definition), initial: state, stdout: stdout}
+	r.reset()
+
+	fmt.Fprintf(stdout, "%s in state %s, type help for the commands\n", machineName(definition), state)
+
+	scanner := bufio.NewScanner(stdin)
+	for {
+		fmt.Fprint(stdout, "fsm> ")
+		if !scanner.Scan() {
+			fmt.Fprintln(stdout)
+
+			break
+		}
+
+		if !r.execute(strings.Fields(scanner.Text())) {
+			break
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitFailure
+	}
+
+	return exitOK
+}
+
+// execute runs a command of the shell, returning false to leave it.
+func (r *repl) execute(fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+
+	switch command, args := fields[0], fields[1:]; command {
+	case "state":
+		fmt.Fprintln(r.stdout, r.instance.Current())
+	case "available":
+		available := r.instance.AvailableTransitions(r.machine)
+		sort.Strings(available)
+		fmt.Fprintln(r.stdout, strings.Join(available, ", "))
+	case "fire":
+		if len(args) == 0 {
+			fmt.Fprintln(r.stdout, "usage: fire <event> [args...]")
+
+			return true
+		}
+
+		step := replStep{event: args[0], args: parseValues(args[1:])}
+		if err := r.apply(step, true); err == nil || errors.As(err, &fsm.NoTransitionError{}) {
+			r.steps = append(r.steps, step)
+		}
+	case "set":
+		if len(args) < 2 {
+			fmt.Fprintln(r.stdout, "usage: set <key> <value>")
+
+			return true
+		}
+
+		step := replStep{key: args[0], value: parseValue(strings.Join(args[1:], " "))}
+		_ = r.apply(step, true)
+		r.steps = append(r.steps, step)
+	case "get":
+		if len(args) != 1 {
+			fmt.Fprintln(r.stdout, "usage: get <key>")
+
+			return true
+		}
+
+		if value, ok := r.instance.GetMetadata(args[0]); ok {
+			fmt.Fprintf(r.stdout, "%v\n", value)
+		} else {
+			fmt.Fprintf(r.stdout, "%s is not set\n", args[0])
+		}
+	case "meta":
+		r.printMetadata()
+	case "undo":
+		r.undo()
+	case "history":
+		for i, step := range r.steps {
+			fmt.Fprintf(r.stdout, "%d. %s\n", i+1, step)
+		}
+	case "diagram":
+		format := fsm.ASCII
+		if len(args) > 0 {
+			format = fsm.VisualizeType(args[0])
+		}
+
+		visualization, err := fsm.VisualizeWithType(r.machine, r.instance, format)
+		if err != nil {
+			fmt.Fprintln(r.stdout, err)
+		} else {
+			fmt.Fprint(r.stdout, visualization)
+		}
+	case "help":
+		fmt.Fprint(r.stdout, replHelp)
+	case "quit", "exit":
+		return false
+	default:
+		fmt.Fprintf(r.stdout, "unknown command %q, type help for the commands\n", command)
+	}
+
+	return true
+}
+
+// apply makes the change of step on the instance, printing its outcome if verbose.
+func (r *repl) apply(step replStep, verbose bool) error {
+	if step.event == "" {
+		r.instance.SetMetadata(step.key, step.value)
+		r.keys[step.key] = true
+
+		return nil
+	}
+
+	src := r.instance.Current()
+	err := r.instance.Transition(r.machine, step.event, step.args...)
+	if verbose {
+		printTransition(r.stdout, step.event, src, r.instance.Current(), err)
+	}
+
+	return err
+}
+
+// reset starts over with a new instance in the initial state.
+func (r *repl) reset() {
+	r.instance = r.machine.NewInstance(r.initial)
+	r.keys = make(map[string]bool)
+}
+
+// undo replays every step but the last one on a new instance, which gives
+// the same result since the callbacks of a definition do nothing.
+func (r *repl) undo() {
+	if len(r.steps) == 0 {
+		fmt.Fprintln(r.stdout, "nothing to undo")
+
+		return
+	}
+
+	last := r.steps[len(r.steps)-1]
+	r.steps = r.steps[:len(r.steps)-1]
+
+	r.reset()
+	for _, step := range r.steps {
+		_ = r.apply(step, false)
+	}
+
+	fmt.Fprintf(r.stdout, "undid %s, now in state %s\n", last, r.instance.Current())
+}
+
+func (r *repl) printMetadata() {
+	keys := make([]string, 0, len(r.keys))
+	for key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value, _ := r.instance.GetMetadata(key)
+		fmt.Fprintf(r.stdout, "%s = %v\n", key, value)
+	}
+}
+
+// parseValues parses each of the fields with parseValue.
+func parseValues(fields []string) []interface{} {
+	values := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		values = append(values, parseValue(field))
+	}
+
+	return values
+}
+
+// parseValue returns the JSON value in s, or s itself if it is not JSON.
+func parseValue(s string) interface{} {
+	var value interface{}
+	if err := json.Unmarshal([]byte(s), &value); err != nil {
+		return s
+	}
+
+	return value
+}
+
+// machineName returns the name of the machine of the definition, or machine if it has none.
+func machineName(definition *fsm.Definition) string {
+	if definition.Name == "" {
+		return "machine"
+	}
+
+	return definition.Name
+}
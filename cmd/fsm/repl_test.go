@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	input := strings.Join([]string{
+		"available",
+		"fire part-open 42 \"x\"",
+		"set driver {\"id\":7}",
+		"fire close",
+		"get driver",
+		"undo",
+		"state",
+		"history",
+		"undo",
+		"meta",
+		"fire lock",
+		"quit",
+	}, "\n")
+
+	code, stdout, _ := runCommandWithInput(input, "repl", writeDefinition(t, doorDefinition))
+
+	wanted := `door in state closed, type help for the commands
+fsm> open, part-open
+fsm> part-open: closed -> ajar
+fsm> fsm> close: ajar -> closed
+fsm> map[id:7]
+fsm> undid fire close, now in state ajar
+fsm> ajar
+fsm> 1. fire part-open 42 x
+2. set driver map[id:7]
+fsm> undid set driver map[id:7], now in state ajar
+fsm> fsm> lock: error: event lock does not exist
+fsm> `
+	if code != exitOK || stdout != wanted {
+		t.Errorf("wanted \n%s\nand got %d and \n%s", wanted, code, stdout)
+	}
+}
+
+func TestREPLDiagram(t *testing.T) {
+	code, stdout, _ := runCommandWithInput("diagram mermaid\n", "repl", "--initial", "open", writeDefinition(t, doorDefinition))
+	if code != exitOK || !strings.Contains(stdout, "[*] --> open") {
+		t.Errorf("expected a mermaid diagram in state open, got %d and \n%s", code, stdout)
+	}
+}
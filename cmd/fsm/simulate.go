@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// runSimulate fires the events in order on an instance of the definition,
+// printing each transition. It stops at the first event that fails.
+func runSimulate(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("simulate", stderr)
+	events := flags.String("events", "", "comma separated events to fire in order")
+	initial := flags.String("initial", "", "state to start in, the initial state of the definition by default")
+
+	definition, code := parseArgs(flags, args, stderr)
+	if definition == nil {
+		return code
+	}
+
+	state, ok := stateOrInitial(definition, *initial, "initial", stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	machine := newMachine(definition)
+	instance := machine.NewInstance(state)
+
+	for _, event := range splitList(*events) {
+		src := instance.Current()
+		err := instance.Transition(machine, event)
+		printTransition(stdout, event, src, instance.Current(), err)
+
+		if err != nil && !errors.As(err, &fsm.NoTransitionError{}) {
+			fmt.Fprintf(stdout, "final state: %s\n", instance.Current())
+
+			return exitFailure
+		}
+	}
+
+	fmt.Fprintf(stdout, "final state: %s\n", instance.Current())
+
+	return exitOK
+}
+
+// printTransition prints the outcome of firing event in src.
+func printTransition(w io.Writer, event, src, dst string, err error) {
+	switch {
+	case err == nil:
+		fmt.Fprintf(w, "%s: %s -> %s\n", event, src, dst)
+	case errors.As(err, &fsm.NoTransitionError{}):
+		fmt.Fprintf(w, "%s: %s -> %s (%s)\n", event, src, dst, err)
+	default:
+		fmt.Fprintf(w, "%s: error: %s\n", event, err)
+	}
+}
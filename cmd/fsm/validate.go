@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// runValidate prints the problems of the definition and fails if there are any.
+func runValidate(args []string, stdout, stderr io.Writer) int {
+	definition, code := parseArgs(newFlagSet("validate", stderr), args, stderr)
+	if definition == nil {
+		return code
+	}
+
+	errs := definition.Validate()
+	for _, err := range errs {
+		fmt.Fprintln(stdout, err)
+	}
+
+	if len(errs) > 0 {
+		return exitFailure
+	}
+
+	fmt.Fprintln(stdout, "ok")
+
+	return exitOK
+}
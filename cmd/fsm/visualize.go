@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// runVisualize prints the visualization of the definition in any of the
+// formats of fsm.VisualizeWithType.
+func runVisualize(args []string, stdout, stderr io.Writer) int {
+	flags := newFlagSet("visualize", stderr)
+	format := flags.String("format", string(fsm.GRAPHVIZ), "output format: graphviz, mermaid, mermaid-state-diagram, mermaid-flow-chart, plantuml, d2, ascii, unicode, svg or html")
+	current := flags.String("current", "", "state to highlight as current, the initial state by default")
+	direction := flags.String("direction", "", "layout direction: TB, LR, BT or RL")
+	output := flags.String("output", "", "file to write to instead of the standard output")
+
+	definition, code := parseArgs(flags, args, stderr)
+	if definition == nil {
+		return code
+	}
+
+	state, ok := stateOrInitial(definition, *current, "current", stderr)
+	if !ok {
+		return exitUsage
+	}
+
+	var options []fsm.VisualizeOption
+	if *direction != "" {
+		options = append(options, fsm.WithDirection(fsm.Direction(*direction)))
+	}
+
+	machine := newMachine(definition)
+	visualization, err := fsm.VisualizeWithType(machine, machine.NewInstance(state), fsm.VisualizeType(*format), options...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitUsage
+	}
+
+	if *output == "" {
+		fmt.Fprint(stdout, visualization)
+
+		return exitOK
+	}
+
+	if err := os.WriteFile(*output, []byte(visualization), 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitFailure
+	}
+
+	return exitOK
+}
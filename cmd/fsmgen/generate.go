@@ -0,0 +1,341 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"text/template"
+	"unicode"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// config describes the code to generate.
+type config struct {
+	packageName string
+	prefix      string
+	name        string
+	transitions []fsm.TransitionDesc
+
+	// variable is the variable holding the transitions in the package, if
+	// they were read from it.
+	variable string
+}
+
+// typePrefix returns the prefix of the generated identifiers.
+func (c config) typePrefix() string {
+	switch {
+	case c.prefix != "":
+		return c.prefix
+	case c.name != "":
+		return goIdentifier(c.name)
+	default:
+		return "Machine"
+	}
+}
+
+// name is a state or an event with its Go identifier.
+type name struct {
+	Value string
+	Ident string
+}
+
+// callback is a field of the generated callbacks struct.
+type callback struct {
+	Field string
+	Name  string
+	Doc   string
+}
+
+// templateData is given to the template of the generated file.
+type templateData struct {
+	Package     string
+	Prefix      string
+	Name        string
+	Variable    string
+	Transitions []fsm.TransitionDesc
+	States      []name
+	Events      []name
+	Callbacks   []callback
+}
+
+// reservedMethods are the methods of the generated instance wrapper which
+// can not be used for events.
+var reservedMethods = map[string]bool{"Instance": true, "Machine": true, "State": true, "Is": true}
+
+// generate returns the formatted source of the typed wrappers.
+func generate(c config) ([]byte, error) {
+	if c.packageName == "" {
+		return nil, fmt.Errorf("no package, -package is required outside of go generate")
+	}
+	if len(c.transitions) == 0 {
+		return nil, fmt.Errorf("no transitions")
+	}
+
+	data := templateData{
+		Package:     c.packageName,
+		Prefix:      c.typePrefix(),
+		Name:        c.name,
+		Variable:    c.variable,
+		Transitions: c.transitions,
+	}
+
+	definition := fsm.Definition{Transitions: c.transitions}
+	states, err := identifiers(definition.States(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("states: %w", err)
+	}
+
+	var eventNames []string
+	seen := make(map[string]bool)
+	for _, transition := range c.transitions {
+		if !seen[transition.Name] {
+			seen[transition.Name] = true
+			eventNames = append(eventNames, transition.Name)
+		}
+	}
+	sort.Strings(eventNames)
+
+	events, err := identifiers(eventNames, reservedMethods)
+	if err != nil {
+		return nil, fmt.Errorf("events: %w", err)
+	}
+
+	for _, event := range events {
+		for _, other := range events {
+			if event.Ident == "Can"+other.Ident {
+				return nil, fmt.Errorf("events: %q maps to %s, the method checking %q", event.Value, event.Ident, other.Value)
+			}
+		}
+	}
+
+	data.States, data.Events = states, events
+	if data.Callbacks, err = callbacks(states, events); err != nil {
+		return nil, fmt.Errorf("callbacks: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	source, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %w", err)
+	}
+
+	return source, nil
+}
+
+// identifiers returns the Go identifiers of values, failing if two of them
+// collide or one of them is reserved.
+func identifiers(values []string, reserved map[string]bool) ([]name, error) {
+	owners := make(map[string]string)
+	names := make([]name, 0, len(values))
+
+	for _, value := range values {
+		ident := goIdentifier(value)
+		if ident == "" {
+			return nil, fmt.Errorf("%q has no Go identifier", value)
+		}
+		if owner, ok := owners[ident]; ok {
+			return nil, fmt.Errorf("%q and %q both map to %s", owner, value, ident)
+		}
+		if reserved[ident] {
+			return nil, fmt.Errorf("%q maps to the reserved name %s", value, ident)
+		}
+
+		owners[ident] = value
+		names = append(names, name{value, ident})
+	}
+
+	return names, nil
+}
+
+// callbacks returns the fields of the callbacks struct, skipping the
+// callbacks fsm.NewMachine takes for the general ones. It fails if two of
+// them map to the same field, like leave_State and the general leave_state.
+func callbacks(states, events []name) ([]callback, error) {
+	list := []callback{
+		{"BeforeTransition", "before_transition", "runs before every transition."},
+		{"LeaveState", "leave_state", "runs when leaving every state."},
+		{"EnterState", "enter_state", "runs when entering every state."},
+		{"AfterTransition", "after_transition", "runs after every transition."},
+	}
+
+	for _, event := range events {
+		if event.Value == "transition" {
+			continue
+		}
+
+		list = append(list,
+			callback{"Before" + event.Ident, "before_" + event.Value, "runs before the " + event.Value + " event."},
+			callback{"After" + event.Ident, "after_" + event.Value, "runs after the " + event.Value + " event."},
+		)
+	}
+
+	for _, state := range states {
+		if state.Value == "state" {
+			continue
+		}
+
+		list = append(list,
+			callback{"Leave" + state.Ident, "leave_" + state.Value, "runs when leaving the " + state.Value + " state."},
+			callback{"Enter" + state.Ident, "enter_" + state.Value, "runs when entering the " + state.Value + " state."},
+		)
+	}
+
+	owners := make(map[string]string, len(list))
+	for _, c := range list {
+		if owner, ok := owners[c.Field]; ok {
+			return nil, fmt.Errorf("%q and %q both map to %s", owner, c.Name, c.Field)
+		}
+
+		owners[c.Field] = c.Name
+	}
+
+	return list, nil
+}
+
+// goIdentifier returns the exported Go identifier of a state or an event,
+// like PartOpen for part-open.
+func goIdentifier(s string) string {
+	var builder strings.Builder
+
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+
+			continue
+		}
+
+		if builder.Len() == 0 && unicode.IsDigit(r) {
+			builder.WriteRune('X')
+		}
+
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		builder.WriteRune(r)
+	}
+
+	return builder.String()
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"quote": func(s string) string { return fmt.Sprintf("%q", s) },
+}).Parse(`// Code generated by fsmgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import fsm "github.com/snapp-incubator/fsm/pkg"
+
+// {{.Prefix}}State is a state of the machine.
+type {{.Prefix}}State string
+
+// The states of the machine.
+const (
+{{- range .States}}
+	{{$.Prefix}}State{{.Ident}} {{$.Prefix}}State = {{quote .Value}}
+{{- end}}
+)
+
+// {{.Prefix}}Event is an event of the machine.
+type {{.Prefix}}Event string
+
+// The events of the machine.
+const (
+{{- range .Events}}
+	{{$.Prefix}}Event{{.Ident}} {{$.Prefix}}Event = {{quote .Value}}
+{{- end}}
+)
+
+// {{.Prefix}}Callbacks are the callbacks of the machine, the nil ones are not registered.
+type {{.Prefix}}Callbacks struct {
+{{- range .Callbacks}}
+	// {{.Field}} {{.Doc}}
+	{{.Field}} fsm.Callback
+{{- end}}
+}
+
+// Map returns the callbacks by their name, as taken by fsm.NewMachine.
+func (c {{.Prefix}}Callbacks) Map() map[string]fsm.Callback {
+	callbacks := make(map[string]fsm.Callback)
+{{- range .Callbacks}}
+	if c.{{.Field}} != nil {
+		callbacks[{{quote .Name}}] = c.{{.Field}}
+	}
+{{- end}}
+
+	return callbacks
+}
+
+// New{{.Prefix}}Machine returns the machine with the given callbacks.
+func New{{.Prefix}}Machine(callbacks {{.Prefix}}Callbacks, options ...fsm.MachineOption) *fsm.Machine {
+{{- if .Name}}
+	options = append([]fsm.MachineOption{fsm.WithName({{quote .Name}})}, options...)
+
+{{- end}}
+{{- if .Variable}}
+	return fsm.NewMachine({{.Variable}}, callbacks.Map(), options...)
+{{- else}}
+	return fsm.NewMachine([]fsm.TransitionDesc{
+	{{- range .Transitions}}
+		{Name: {{quote .Name}}, Sources: []string{ {{- range $i, $s := .Sources}}{{if $i}}, {{end}}{{quote $s}}{{end -}} }, Destination: {{quote .Destination}}},
+	{{- end}}
+	}, callbacks.Map(), options...)
+{{- end}}
+}
+
+// {{.Prefix}}Instance is an instance of the machine with typed methods.
+type {{.Prefix}}Instance struct {
+	machine  *fsm.Machine
+	instance *fsm.Instance
+}
+
+// New{{.Prefix}}Instance returns a new instance of machine in the initial state.
+func New{{.Prefix}}Instance(machine *fsm.Machine, initial {{.Prefix}}State, options ...fsm.InstanceOption) *{{.Prefix}}Instance {
+	return &{{.Prefix}}Instance{machine: machine, instance: machine.NewInstance(string(initial), options...)}
+}
+
+// Wrap{{.Prefix}}Instance returns the typed wrapper of an existing instance of machine.
+func Wrap{{.Prefix}}Instance(machine *fsm.Machine, instance *fsm.Instance) *{{.Prefix}}Instance {
+	return &{{.Prefix}}Instance{machine: machine, instance: instance}
+}
+
+// Instance returns the wrapped instance.
+func (i *{{.Prefix}}Instance) Instance() *fsm.Instance {
+	return i.instance
+}
+
+// Machine returns the machine of the instance.
+func (i *{{.Prefix}}Instance) Machine() *fsm.Machine {
+	return i.machine
+}
+
+// State returns the current state of the instance.
+func (i *{{.Prefix}}Instance) State() {{.Prefix}}State {
+	return {{.Prefix}}State(i.instance.Current())
+}
+
+// Is returns true if state is the current state.
+func (i *{{.Prefix}}Instance) Is(state {{.Prefix}}State) bool {
+	return i.instance.Is(string(state))
+}
+{{- range .Events}}
+
+// {{.Ident}} fires the {{.Value}} event.
+func (i *{{$.Prefix}}Instance) {{.Ident}}(args ...interface{}) error {
+	return i.instance.Transition(i.machine, string({{$.Prefix}}Event{{.Ident}}), args...)
+}
+
+// Can{{.Ident}} returns true if the {{.Value}} event can be fired in the current state.
+func (i *{{$.Prefix}}Instance) Can{{.Ident}}() bool {
+	return i.instance.Can(i.machine, string({{$.Prefix}}Event{{.Ident}}))
+}
+{{- end}}
+`))
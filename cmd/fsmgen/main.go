@@ -0,0 +1,91 @@
+// Command fsmgen generates typed wrappers for a machine, to be run with
+// go generate. It reads the transitions from a definition file (see
+// fsm.Definition) or from a []fsm.TransitionDesc variable of the package, and
+// generates:
+//
+//   - typed constants for the states and events,
+//   - an instance wrapper with a method firing each event, like Open(), and
+//     another checking if it can be fired, like CanOpen(),
+//   - a struct of typed callbacks building the map given to fsm.NewMachine,
+//   - a constructor of the machine.
+//
+// Renaming an event or a state then breaks the build of the code using the
+// old name instead of silently dropping its callbacks.
+//
+// Usage:
+//
+//	//go:generate go run github.com/snapp-incubator/fsm/cmd/fsmgen -definition door.json -prefix Door
+//	//go:generate go run github.com/snapp-incubator/fsm/cmd/fsmgen -var doorTransitions -prefix Door
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("fsmgen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	definitionPath := flags.String("definition", "", "definition file to read the transitions from")
+	variable := flags.String("var", "", "[]fsm.TransitionDesc variable of the package to read the transitions from")
+	dir := flags.String("dir", ".", "directory of the package")
+	packageName := flags.String("package", os.Getenv("GOPACKAGE"), "package of the generated file, $GOPACKAGE by default")
+	prefix := flags.String("prefix", "", "prefix of the generated identifiers, the name of the definition by default")
+	output := flags.String("output", "", "generated file, <prefix>_fsm.go by default")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	config := config{packageName: *packageName, prefix: *prefix}
+
+	var err error
+	switch {
+	case *definitionPath != "" && *variable == "":
+		var definition *fsm.Definition
+		definition, err = fsm.LoadDefinition(*definitionPath)
+		if err == nil {
+			config.transitions = definition.Transitions
+			config.name = definition.Name
+		}
+	case *variable != "" && *definitionPath == "":
+		config.transitions, config.packageName, err = loadVariable(*dir, *variable, config.packageName)
+		config.variable = *variable
+	default:
+		err = errors.New("exactly one of -definition and -var is required")
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return 1
+	}
+
+	source, err := generate(config)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return 1
+	}
+
+	if *output == "" {
+		*output = strings.ToLower(config.typePrefix()) + "_fsm.go"
+	}
+
+	if err := os.WriteFile(*output, source, 0o644); err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func TestGenerateFromDefinition(t *testing.T) {
+	dir := t.TempDir()
+	definition := filepath.Join(dir, "door.json")
+	err := os.WriteFile(definition, []byte(`{"name": "door", "transitions": [
+		{"name": "open", "sources": ["closed"], "destination": "open"},
+		{"name": "part-open", "sources": ["closed"], "destination": "ajar"}
+	]}`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "door_fsm.go")
+
+	var stderr bytes.Buffer
+	if code := run([]string{"-definition", definition, "-package", "door", "-output", output}, &stderr); code != 0 {
+		t.Fatalf("expected generation to succeed, got %d: %s", code, stderr.String())
+	}
+
+	source, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range []string{
+		"package door\n",
+		`DoorStateAjar   DoorState = "ajar"`,
+		`DoorEventPartOpen DoorEvent = "part-open"`,
+		`callbacks["before_part-open"] = c.BeforePartOpen`,
+		`options = append([]fsm.MachineOption{fsm.WithName("door")}, options...)`,
+		`{Name: "part-open", Sources: []string{"closed"}, Destination: "ajar"},`,
+		"func (i *DoorInstance) PartOpen(args ...interface{}) error {",
+		"func (i *DoorInstance) CanPartOpen() bool {",
+	} {
+		if !strings.Contains(string(source), part) {
+			t.Errorf("expected generated code to contain %q, got \n%s", part, source)
+		}
+	}
+}
+
+func TestGenerateFromVariable(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "light.go"), []byte(`package light
+
+import fsm "github.com/snapp-incubator/fsm/pkg"
+
+var transitions = []fsm.TransitionDesc{
+	{Name: "on", Sources: []string{"off"}, Destination: "on"},
+	{"off", []string{"on"}, "off"},
+}
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, packageName, err := loadVariable(dir, "transitions", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []fsm.TransitionDesc{
+		{Name: "on", Sources: []string{"off"}, Destination: "on"},
+		{Name: "off", Sources: []string{"on"}, Destination: "off"},
+	}
+	if packageName != "light" || len(got) != 2 || got[0].Name != wanted[0].Name || got[1].Sources[0] != wanted[1].Sources[0] {
+		t.Errorf("expected %v in package light, got %v in package %s", wanted, got, packageName)
+	}
+
+	source, err := generate(config{packageName: packageName, prefix: "Light", transitions: got, variable: "transitions"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(source), "return fsm.NewMachine(transitions, callbacks.Map(), options...)") {
+		t.Errorf("expected the machine to be built from the variable, got \n%s", source)
+	}
+}
+
+func TestGenerateCollisions(t *testing.T) {
+	tests := []struct {
+		transitions []fsm.TransitionDesc
+		wanted      string
+	}{
+		{
+			[]fsm.TransitionDesc{{Name: "part-open", Sources: []string{"a"}, Destination: "b"}, {Name: "part_open", Sources: []string{"a"}, Destination: "b"}},
+			`events: "part-open" and "part_open" both map to PartOpen`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "state", Sources: []string{"a"}, Destination: "b"}},
+			`events: "state" maps to the reserved name State`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "open", Sources: []string{"a"}, Destination: "b"}, {Name: "can-open", Sources: []string{"a"}, Destination: "b"}},
+			`events: "can-open" maps to CanOpen, the method checking "open"`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "open", Sources: []string{"State"}, Destination: "b"}},
+			`callbacks: "leave_state" and "leave_State" both map to LeaveState`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "Transition", Sources: []string{"a"}, Destination: "b"}},
+			`callbacks: "before_transition" and "before_Transition" both map to BeforeTransition`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "open", Sources: []string{"*"}, Destination: "b"}},
+			`states: "*" has no Go identifier`,
+		},
+		{
+			[]fsm.TransitionDesc{{Name: "+", Sources: []string{"a"}, Destination: "b"}},
+			`events: "+" has no Go identifier`,
+		},
+	}
+
+	for _, test := range tests {
+		_, err := generate(config{packageName: "p", transitions: test.transitions})
+		if err == nil || err.Error() != test.wanted {
+			t.Errorf("expected error %q, got %v", test.wanted, err)
+		}
+	}
+}
+
+func TestGenerateCompiles(t *testing.T) {
+	definitions := [][]fsm.TransitionDesc{
+		{
+			{Name: "open", Sources: []string{"closed", "ajar"}, Destination: "open"},
+			{Name: "part-open", Sources: []string{"closed"}, Destination: "ajar"},
+			{Name: "close", Sources: []string{"open", "ajar"}, Destination: "closed"},
+			{Name: "transition", Sources: []string{"open"}, Destination: "state"},
+		},
+		{
+			{Name: "*", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "+", Sources: []string{"closed"}, Destination: "open"},
+		},
+		{
+			{Name: "open", Sources: []string{"*"}, Destination: "-"},
+		},
+		{
+			{Name: "+open+", Sources: []string{"*closed*"}, Destination: "open"},
+		},
+	}
+
+	for _, transitions := range definitions {
+		source, err := generate(config{packageName: "door", name: "door", transitions: transitions})
+		if err != nil {
+			// names which can't be generated must be rejected rather than
+			// generating code which doesn't compile
+			if !strings.Contains(err.Error(), "has no Go identifier") {
+				t.Errorf("expected %v to compile or to be rejected, got %v", transitions, err)
+			}
+
+			continue
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, "door_fsm.go", source, 0)
+		if err != nil {
+			t.Errorf("expected the generated code to parse, got %s in \n%s", err, source)
+
+			continue
+		}
+
+		conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+		if _, err := conf.Check("door", fset, []*ast.File{file}, nil); err != nil {
+			t.Errorf("expected the generated code to compile, got %s in \n%s", err, source)
+		}
+	}
+}
+
+func TestGoIdentifier(t *testing.T) {
+	for value, wanted := range map[string]string{
+		"open":            "Open",
+		"part-open":       "PartOpen",
+		"payment.pending": "PaymentPending",
+		"2fa":             "X2fa",
+		"état":            "État",
+	} {
+		if got := goIdentifier(value); got != wanted {
+			t.Errorf("expected %s for %q, got %s", wanted, value, got)
+		}
+	}
+}
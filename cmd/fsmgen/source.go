@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// loadVariable reads the transitions assigned to the variable name in the Go
+// files of dir. The transitions must be written with string literals. It also
+// returns the package of the files if packageName is empty.
+func loadVariable(dir, name, packageName string) ([]fsm.TransitionDesc, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", err
+	}
+
+	files := token.NewFileSet()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".go") || strings.HasSuffix(entry.Name(), "_test.go") {
+			continue
+		}
+
+		file, err := parser.ParseFile(files, filepath.Join(dir, entry.Name()), nil, 0)
+		if err != nil {
+			return nil, "", err
+		}
+
+		for _, decl := range file.Decls {
+			value, ok := findValue(decl, name)
+			if !ok {
+				continue
+			}
+
+			if packageName == "" {
+				packageName = file.Name.Name
+			}
+
+			transitions, err := parseTransitions(files, value)
+
+			return transitions, packageName, err
+		}
+	}
+
+	return nil, "", fmt.Errorf("variable %s not found in %s", name, dir)
+}
+
+// findValue returns the value of the variable name if it is declared in decl.
+func findValue(decl ast.Decl, name string) (ast.Expr, bool) {
+	gen, ok := decl.(*ast.GenDecl)
+	if !ok || gen.Tok != token.VAR {
+		return nil, false
+	}
+
+	for _, spec := range gen.Specs {
+		value := spec.(*ast.ValueSpec)
+		for i, ident := range value.Names {
+			if ident.Name == name && i < len(value.Values) {
+				return value.Values[i], true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// parseTransitions reads the []fsm.TransitionDesc composite literal expr.
+func parseTransitions(files *token.FileSet, expr ast.Expr) ([]fsm.TransitionDesc, error) {
+	list, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a []fsm.TransitionDesc literal", files.Position(expr.Pos()))
+	}
+
+	var transitions []fsm.TransitionDesc
+	for _, elt := range list.Elts {
+		if unary, ok := elt.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+			elt = unary.X
+		}
+
+		desc, ok := elt.(*ast.CompositeLit)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected a fsm.TransitionDesc literal", files.Position(elt.Pos()))
+		}
+
+		var transition fsm.TransitionDesc
+		for i, field := range desc.Elts {
+			key, value := fieldOf(i, field)
+
+			var err error
+			switch key {
+			case "Name":
+				transition.Name, err = stringOf(files, value)
+			case "Destination":
+				transition.Destination, err = stringOf(files, value)
+			case "Sources":
+				transition.Sources, err = stringsOf(files, value)
+			default:
+				err = fmt.Errorf("%s: unexpected field", files.Position(field.Pos()))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		transitions = append(transitions, transition)
+	}
+
+	return transitions, nil
+}
+
+// fieldOf returns the name and value of the i-th field of a TransitionDesc literal.
+func fieldOf(i int, field ast.Expr) (string, ast.Expr) {
+	if kv, ok := field.(*ast.KeyValueExpr); ok {
+		if ident, ok := kv.Key.(*ast.Ident); ok {
+			return ident.Name, kv.Value
+		}
+
+		return "", kv.Value
+	}
+
+	positional := []string{"Name", "Sources", "Destination"}
+	if i < len(positional) {
+		return positional[i], field
+	}
+
+	return "", field
+}
+
+func stringOf(files *token.FileSet, expr ast.Expr) (string, error) {
+	literal, ok := expr.(*ast.BasicLit)
+	if !ok || literal.Kind != token.STRING {
+		return "", fmt.Errorf("%s: expected a string literal", files.Position(expr.Pos()))
+	}
+
+	return strconv.Unquote(literal.Value)
+}
+
+func stringsOf(files *token.FileSet, expr ast.Expr) ([]string, error) {
+	list, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected a []string literal", files.Position(expr.Pos()))
+	}
+
+	var values []string
+	for _, elt := range list.Elts {
+		value, err := stringOf(files, elt)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, nil
+}
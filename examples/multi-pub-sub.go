@@ -9,6 +9,8 @@ import (
 )
 
 func main() {
+	message := fsm.NewKey[string]("pubsub", "message")
+
 	counter := 0
 
 	machine := fsm.NewMachine(
@@ -19,14 +21,14 @@ func main() {
 		map[string]fsm.Callback{
 			"publish": func(e *fsm.Transition) {
 				msg := fmt.Sprintf("counter:%d", counter)
-				e.Instance.SetMetadata("message", msg)
+				message.Set(e.Instance, msg)
 				fmt.Println("published data")
 				counter++
 			},
 			"subscribe": func(e *fsm.Transition) {
-				message, ok := e.Instance.GetMetadata("message")
+				value, ok := message.Get(e.Instance)
 				if ok {
-					fmt.Println("message = " + message.(string))
+					fmt.Println("message = " + value)
 				}
 			},
 		},
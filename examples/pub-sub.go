@@ -9,6 +9,8 @@ import (
 )
 
 func main() {
+	message := fsm.NewKey[string]("pubsub", "message")
+
 	machine := fsm.NewMachine(
 		[]fsm.TransitionDesc{
 			{Name: "publish", Sources: []string{"idle"}, Destination: "idle"},
@@ -16,13 +18,13 @@ func main() {
 		},
 		map[string]fsm.Callback{
 			"publish": func(e *fsm.Transition) {
-				e.Instance.SetMetadata("message", "hii")
+				message.Set(e.Instance, "hii")
 				fmt.Println("published data")
 			},
 			"subscribe": func(e *fsm.Transition) {
-				message, ok := e.Instance.GetMetadata("message")
+				value, ok := message.Get(e.Instance)
 				if ok {
-					fmt.Println("message = " + message.(string))
+					fmt.Println("message = " + value)
 				}
 
 			},
@@ -0,0 +1,329 @@
+package pkg
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMailboxSize is the default capacity of an Actor's mailbox.
+const DefaultMailboxSize = 64
+
+// OverflowPolicy decides what an Actor does with an event sent to a full
+// mailbox.
+type OverflowPolicy uint8
+
+const (
+	// Block waits until there is room in the mailbox.
+	Block OverflowPolicy = iota
+	// Reject returns MailboxFullError without queueing the event.
+	Reject
+	// DropOldest discards the oldest queued event to make room for the new one.
+	// The discarded event fails with MailboxFullError.
+	DropOldest
+)
+
+// ShutdownPolicy decides what an Actor does with queued events when stopped.
+type ShutdownPolicy uint8
+
+const (
+	// Drain processes all queued events before the actor stops.
+	Drain ShutdownPolicy = iota
+	// Discard fails all queued events with ActorStoppedError.
+	Discard
+)
+
+// ActorOption configures optional behaviour of an Actor.
+type ActorOption func(*Actor)
+
+// WithMailboxSize sets the capacity of the actor's mailbox.
+func WithMailboxSize(size int) ActorOption {
+	return func(actor *Actor) {
+		actor.mailboxSize = size
+	}
+}
+
+// WithOverflowPolicy sets what happens when an event is sent to a full mailbox.
+func WithOverflowPolicy(policy OverflowPolicy) ActorOption {
+	return func(actor *Actor) {
+		actor.overflow = policy
+	}
+}
+
+// WithShutdownPolicy sets what happens with queued events on Stop.
+func WithShutdownPolicy(policy ShutdownPolicy) ActorOption {
+	return func(actor *Actor) {
+		actor.shutdown = policy
+	}
+}
+
+// WithErrorHandler sets a function called with the errors of events sent with
+// Send, which have no caller waiting for them.
+func WithErrorHandler(handler func(event string, err error)) ActorOption {
+	return func(actor *Actor) {
+		actor.onError = handler
+	}
+}
+
+// Actor owns an Instance and processes its events one by one in a dedicated
+// goroutine, so callers never contend on the instance locks and a slow
+// callback does not block readers of the current state.
+//
+// It has to be created with NewActor and stopped with Stop.
+type Actor struct {
+	machine  *Machine
+	instance *Instance
+
+	mailboxSize int
+	overflow    OverflowPolicy
+	shutdown    ShutdownPolicy
+	onError     func(event string, err error)
+
+	// mailbox holds the events waiting to be processed.
+	mailbox chan actorMessage
+	// current caches the state of the instance after the last processed event.
+	current atomic.Value
+
+	// closed is set by Stop, after which no event is accepted.
+	closed bool
+	// senders counts the events being put in the mailbox, which the actor
+	// waits for before its final drain.
+	senders sync.WaitGroup
+	// closedMu guards closed and senders against concurrent senders. It is
+	// never held while waiting for room in the mailbox.
+	closedMu sync.Mutex
+
+	// stopping is closed by Stop to wake the actor and the blocked senders.
+	stopping chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// completeTransition names the messages queued by Complete in errors.
+const completeTransition = "CompleteTransition"
+
+// actorMessage is an event waiting in the mailbox.
+type actorMessage struct {
+	name string
+	args []interface{}
+	// complete is set for the completion of an asynchronous transition.
+	complete bool
+	// reply receives the result of the transition, nil for Send.
+	reply chan error
+}
+
+// NewActor starts an actor processing events for instance using machine.
+// The instance must not be used directly while the actor is running, so
+// asynchronous transitions started by its callbacks are completed with
+// Complete.
+func NewActor(machine *Machine, instance *Instance, options ...ActorOption) *Actor {
+	actor := &Actor{
+		machine:     machine,
+		instance:    instance,
+		mailboxSize: DefaultMailboxSize,
+		stopping:    make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	for _, option := range options {
+		option(actor)
+	}
+
+	actor.mailbox = make(chan actorMessage, actor.mailboxSize)
+	actor.current.Store(instance.Current())
+
+	go actor.run()
+
+	return actor
+}
+
+// Current returns the state of the instance after the last processed event.
+// It never waits for a running transition.
+func (a *Actor) Current() string {
+	current, _ := a.current.Load().(string)
+
+	return current
+}
+
+// Send queues the named event without waiting for it to be processed.
+// Errors of the transition are passed to the handler set by WithErrorHandler.
+//
+// It returns MailboxFullError if the mailbox is full and the overflow policy
+// is Reject, or ActorStoppedError if the actor has been stopped.
+func (a *Actor) Send(name string, args ...interface{}) error {
+	return a.enqueue(context.Background(), actorMessage{name: name, args: args})
+}
+
+// Ask queues the named event and waits until it is processed, returning the
+// error of the transition. It returns the context's error if ctx is done
+// first, in which case the event may still be processed later.
+func (a *Actor) Ask(ctx context.Context, name string, args ...interface{}) error {
+	return a.ask(ctx, actorMessage{name: name, args: args, reply: make(chan error, 1)})
+}
+
+// Complete queues the completion of the asynchronous transition started by
+// the last processed event and waits until it is processed, returning the
+// error of Instance.CompleteTransition. Like Ask, it returns the context's
+// error if ctx is done first. The errors returned before processing, like
+// ActorStoppedError, name the completion CompleteTransition.
+func (a *Actor) Complete(ctx context.Context) error {
+	return a.ask(ctx, actorMessage{name: completeTransition, complete: true, reply: make(chan error, 1)})
+}
+
+// ask queues msg and waits until it is processed.
+func (a *Actor) ask(ctx context.Context, msg actorMessage) error {
+	if err := a.enqueue(ctx, msg); err != nil {
+		return err
+	}
+
+	select {
+	case err := <-msg.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops accepting events and, depending on the shutdown policy, processes
+// or discards the queued ones. It waits until the actor's goroutine exits or
+// ctx is done.
+func (a *Actor) Stop(ctx context.Context) error {
+	a.closedMu.Lock()
+	a.closed = true
+	a.closedMu.Unlock()
+
+	a.stopOnce.Do(func() {
+		close(a.stopping)
+	})
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	select {
+	case <-a.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// enqueue puts msg in the mailbox according to the overflow policy.
+func (a *Actor) enqueue(ctx context.Context, msg actorMessage) error {
+	a.closedMu.Lock()
+	if a.closed {
+		a.closedMu.Unlock()
+
+		return ActorStoppedError{msg.name}
+	}
+	a.senders.Add(1)
+	a.closedMu.Unlock()
+
+	defer a.senders.Done()
+
+	select {
+	case a.mailbox <- msg:
+		return nil
+	default:
+	}
+
+	switch a.overflow {
+	case Reject:
+		return MailboxFullError{msg.name}
+	case DropOldest:
+		for {
+			select {
+			case a.mailbox <- msg:
+				return nil
+			case dropped := <-a.mailbox:
+				a.reply(dropped, MailboxFullError{dropped.name})
+			case <-a.stopping:
+				return ActorStoppedError{msg.name}
+			}
+		}
+	default:
+		select {
+		case a.mailbox <- msg:
+			return nil
+		case <-a.stopping:
+			return ActorStoppedError{msg.name}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// run processes the mailbox until the actor is stopped.
+func (a *Actor) run() {
+	defer close(a.done)
+
+	for {
+		// give priority to stopping over the queued events
+		select {
+		case <-a.stopping:
+			a.stop()
+
+			return
+		default:
+		}
+
+		select {
+		case msg := <-a.mailbox:
+			a.process(msg)
+		case <-a.stopping:
+			a.stop()
+
+			return
+		}
+	}
+}
+
+// stop drains the mailbox, again once the senders that were putting events in
+// it when the actor was stopped returned.
+func (a *Actor) stop() {
+	a.drain()
+	a.senders.Wait()
+	a.drain()
+}
+
+// drain empties the mailbox once no more events can be sent.
+func (a *Actor) drain() {
+	for {
+		select {
+		case msg := <-a.mailbox:
+			if a.shutdown == Discard {
+				a.reply(msg, ActorStoppedError{msg.name})
+			} else {
+				a.process(msg)
+			}
+		default:
+			return
+		}
+	}
+}
+
+// process runs the transition for msg and reports its result.
+func (a *Actor) process(msg actorMessage) {
+	var err error
+	if msg.complete {
+		err = a.instance.CompleteTransition(a.machine)
+	} else {
+		err = a.instance.Transition(a.machine, msg.name, msg.args...)
+	}
+
+	a.current.Store(a.instance.Current())
+	a.reply(msg, err)
+}
+
+// reply delivers the result of msg to its waiter or the error handler.
+func (a *Actor) reply(msg actorMessage, err error) {
+	if msg.reply != nil {
+		msg.reply <- err
+
+		return
+	}
+
+	if err != nil && a.onError != nil {
+		a.onError(msg.name, err)
+	}
+}
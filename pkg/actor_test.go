@@ -0,0 +1,290 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newBlockingMachine returns a machine whose "work" event blocks in its
+// callback until release is closed, signalling started when it begins.
+func newBlockingMachine(started chan<- struct{}, release <-chan struct{}) *Machine {
+	return NewMachine(
+		[]TransitionDesc{
+			{Name: "work", Sources: []string{"idle"}, Destination: "busy"},
+			{Name: "rest", Sources: []string{"busy"}, Destination: "idle"},
+		},
+		map[string]Callback{
+			"leave_idle": func(t *Transition) {
+				started <- struct{}{}
+				<-release
+			},
+		},
+	)
+}
+
+func TestActorAsk(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{},
+	)
+
+	actor := NewActor(machine, machine.NewInstance("closed"))
+	defer actor.Stop(context.Background())
+
+	if err := actor.Ask(context.Background(), "open"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if actor.Current() != "open" {
+		t.Errorf("expected state to be open, got %s", actor.Current())
+	}
+
+	if err := actor.Ask(context.Background(), "open"); !errors.As(err, new(InvalidEventError)) {
+		t.Errorf("expected InvalidEventError, got %v", err)
+	}
+}
+
+func TestActorComplete(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{
+			"leave_closed": func(e *Transition) {
+				e.Async()
+			},
+		},
+	)
+
+	actor := NewActor(machine, machine.NewInstance("closed"))
+	defer actor.Stop(context.Background())
+
+	if err := actor.Ask(context.Background(), "open"); !errors.As(err, new(AsyncError)) {
+		t.Fatalf("expected AsyncError, got %v", err)
+	}
+	if err := actor.Ask(context.Background(), "open"); !errors.As(err, new(InTransitionError)) {
+		t.Errorf("expected InTransitionError, got %v", err)
+	}
+
+	if err := actor.Complete(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if actor.Current() != "open" {
+		t.Errorf("expected state to be open, got %s", actor.Current())
+	}
+
+	if err := actor.Complete(context.Background()); !errors.As(err, new(NotInTransitionError)) {
+		t.Errorf("expected NotInTransitionError, got %v", err)
+	}
+
+	_ = actor.Stop(context.Background())
+	if err := actor.Complete(context.Background()); err == nil || err.Error() != "event CompleteTransition rejected because the actor is stopped" {
+		t.Errorf("expected ActorStoppedError, got %v", err)
+	}
+}
+
+func TestActorCurrentDoesNotBlock(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	machine := newBlockingMachine(started, release)
+
+	actor := NewActor(machine, machine.NewInstance("idle"))
+	defer actor.Stop(context.Background())
+
+	if err := actor.Send("work"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	<-started
+
+	if actor.Current() != "idle" {
+		t.Errorf("expected state to be idle during the transition, got %s", actor.Current())
+	}
+
+	close(release)
+
+	if err := actor.Ask(context.Background(), "rest"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestActorReject(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	machine := newBlockingMachine(started, release)
+
+	actor := NewActor(machine, machine.NewInstance("idle"), WithMailboxSize(1), WithOverflowPolicy(Reject))
+	defer actor.Stop(context.Background())
+
+	_ = actor.Send("work")
+	<-started
+
+	if err := actor.Send("rest"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := actor.Send("rest"); !errors.As(err, new(MailboxFullError)) {
+		t.Errorf("expected MailboxFullError, got %v", err)
+	}
+
+	close(release)
+}
+
+func TestActorDropOldest(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	machine := newBlockingMachine(started, release)
+
+	actor := NewActor(machine, machine.NewInstance("idle"), WithMailboxSize(1), WithOverflowPolicy(DropOldest))
+	defer actor.Stop(context.Background())
+
+	_ = actor.Send("work")
+	<-started
+
+	dropped := make(chan error, 1)
+	go func() {
+		dropped <- actor.Ask(context.Background(), "unknown")
+	}()
+
+	// wait until the ask is queued before overflowing the mailbox
+	for len(actor.mailbox) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := actor.Send("rest"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := <-dropped; !errors.As(err, new(MailboxFullError)) {
+		t.Errorf("expected MailboxFullError, got %v", err)
+	}
+
+	close(release)
+}
+
+func TestActorStop(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   ShutdownPolicy
+		expected string
+	}{
+		{name: "drain", policy: Drain, expected: "idle"},
+		{name: "discard", policy: Discard, expected: "busy"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			machine := newBlockingMachine(started, release)
+			instance := machine.NewInstance("idle")
+
+			actor := NewActor(machine, instance, WithShutdownPolicy(test.policy))
+
+			_ = actor.Send("work")
+			<-started
+
+			rest := make(chan error, 1)
+			go func() {
+				rest <- actor.Ask(context.Background(), "rest")
+			}()
+
+			for len(actor.mailbox) == 0 {
+				time.Sleep(time.Millisecond)
+			}
+
+			stopped := make(chan error, 1)
+			go func() {
+				stopped <- actor.Stop(context.Background())
+			}()
+
+			<-actor.stopping
+			close(release)
+
+			if err := <-stopped; err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			err := <-rest
+			if test.policy == Discard && !errors.As(err, new(ActorStoppedError)) {
+				t.Errorf("expected ActorStoppedError, got %v", err)
+			}
+
+			if instance.Current() != test.expected {
+				t.Errorf("expected state to be %s, got %s", test.expected, instance.Current())
+			}
+
+			if err := actor.Send("work"); !errors.As(err, new(ActorStoppedError)) {
+				t.Errorf("expected ActorStoppedError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestActorStopWithFullMailbox(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	machine := newBlockingMachine(started, release)
+
+	actor := NewActor(machine, machine.NewInstance("idle"), WithMailboxSize(1))
+
+	_ = actor.Send("work")
+	<-started
+	_ = actor.Send("rest")
+
+	// the sender waits on its context once the mailbox is full
+	waiting := &waitingContext{Context: context.Background(), waiting: make(chan struct{})}
+	blocked := make(chan error, 1)
+	go func() {
+		blocked <- actor.Ask(waiting, "rest")
+	}()
+	<-waiting.waiting
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := actor.Stop(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected Stop to honor its deadline, returned after %s", elapsed)
+	}
+
+	select {
+	case err := <-blocked:
+		if !errors.As(err, new(ActorStoppedError)) {
+			t.Errorf("expected the blocked sender to get ActorStoppedError, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the blocked sender to be released by Stop")
+	}
+
+	close(release)
+	if err := actor.Stop(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+// waitingContext signals when Done is first called, which a sender does once
+// it has to wait for room in the mailbox.
+type waitingContext struct {
+	context.Context
+
+	waiting chan struct{}
+	once    sync.Once
+}
+
+func (c *waitingContext) Done() <-chan struct{} {
+	c.once.Do(func() {
+		close(c.waiting)
+	})
+
+	return c.Context.Done()
+}
@@ -1,5 +1,7 @@
 package pkg
 
+import "sort"
+
 // Callback is a function type that callbacks should use.
 // Transition is the current transition as the callback happens.
 type Callback func(*Transition)
@@ -14,6 +16,79 @@ const (
 	callbackAfterTransition
 )
 
+// HookType is the stage of a transition at which a callback runs.
+type HookType string
+
+const (
+	// HookBeforeTransition is the stage of before_<EVENT> and before_transition.
+	HookBeforeTransition HookType = "before"
+	// HookLeaveState is the stage of leave_<STATE> and leave_state.
+	HookLeaveState HookType = "leave"
+	// HookEnterState is the stage of enter_<STATE> and enter_state.
+	HookEnterState HookType = "enter"
+	// HookAfterTransition is the stage of after_<EVENT> and after_transition.
+	HookAfterTransition HookType = "after"
+)
+
+// Hook identifies a callback of a machine.
+type Hook struct {
+	// Type is the stage of the transition at which the callback runs.
+	Type HookType
+
+	// Target is the state or event the callback is registered for, or "" for
+	// the general callbacks like before_transition.
+	Target string
+}
+
+// String returns the name the callback is registered with, like before_open
+// or enter_state.
+func (h Hook) String() string {
+	if h.Target != "" {
+		return string(h.Type) + "_" + h.Target
+	}
+
+	switch h.Type {
+	case HookLeaveState, HookEnterState:
+		return string(h.Type) + "_state"
+	default:
+		return string(h.Type) + "_transition"
+	}
+}
+
+// hookCallbackTypes maps the hook types to the callback types.
+var hookCallbackTypes = map[HookType]callbackType{
+	HookBeforeTransition: callbackBeforeTransition,
+	HookLeaveState:       callbackLeaveState,
+	HookEnterState:       callbackEnterState,
+	HookAfterTransition:  callbackAfterTransition,
+}
+
+// Hooks returns the callbacks registered on the machine, sorted by name.
+func (machine *Machine) Hooks() []Hook {
+	hookTypes := make(map[callbackType]HookType, len(hookCallbackTypes))
+	for hookType, callbackType := range hookCallbackTypes {
+		hookTypes[callbackType] = hookType
+	}
+
+	hooks := make([]Hook, 0, len(machine.callbacks))
+	for key := range machine.callbacks {
+		hooks = append(hooks, Hook{hookTypes[key.callbackType], key.target})
+	}
+
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].String() < hooks[j].String()
+	})
+
+	return hooks
+}
+
+// hasCallback returns true if a callback is registered for hook.
+func (machine *Machine) hasCallback(hook Hook) bool {
+	_, ok := machine.callbacks[callbackKey{hook.Target, hookCallbackTypes[hook.Type]}]
+
+	return ok
+}
+
 // cKey is a struct key used for keeping the callbacks mapped to a target.
 type callbackKey struct {
 	// target is either the name of a state or an event depending on which
@@ -28,7 +103,7 @@ type callbackKey struct {
 // beforeEventCallbacks calls the before_ callbacks, first the named then the general version.
 func (f *Instance) beforeEventCallbacks(machine *Machine, t *Transition) error {
 	if fn, ok := machine.callbacks[callbackKey{t.Name, callbackBeforeTransition}]; ok {
-		fn(t)
+		machine.runCallback(fn, t, Hook{HookBeforeTransition, t.Name})
 
 		if t.canceled {
 			return CanceledError{t.Err}
@@ -36,7 +111,7 @@ func (f *Instance) beforeEventCallbacks(machine *Machine, t *Transition) error {
 	}
 
 	if fn, ok := machine.callbacks[callbackKey{"", callbackBeforeTransition}]; ok {
-		fn(t)
+		machine.runCallback(fn, t, Hook{HookBeforeTransition, ""})
 
 		if t.canceled {
 			return CanceledError{t.Err}
@@ -49,7 +124,7 @@ func (f *Instance) beforeEventCallbacks(machine *Machine, t *Transition) error {
 // leaveStateCallbacks calls the leave_ callbacks, first the named then the general version.
 func (f *Instance) leaveStateCallbacks(machine *Machine, e *Transition) error {
 	if fn, ok := machine.callbacks[callbackKey{f.current, callbackLeaveState}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookLeaveState, f.current})
 
 		if e.canceled {
 			return CanceledError{e.Err}
@@ -59,7 +134,7 @@ func (f *Instance) leaveStateCallbacks(machine *Machine, e *Transition) error {
 	}
 
 	if fn, ok := machine.callbacks[callbackKey{"", callbackLeaveState}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookLeaveState, ""})
 
 		if e.canceled {
 			return CanceledError{e.Err}
@@ -74,21 +149,21 @@ func (f *Instance) leaveStateCallbacks(machine *Machine, e *Transition) error {
 // enterStateCallbacks calls the enter_ callbacks, first the named then the general version.
 func (f *Instance) enterStateCallbacks(machine *Machine, e *Transition) {
 	if fn, ok := machine.callbacks[callbackKey{f.current, callbackEnterState}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookEnterState, f.current})
 	}
 
 	if fn, ok := machine.callbacks[callbackKey{"", callbackEnterState}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookEnterState, ""})
 	}
 }
 
 // afterEventCallbacks calls the after_ callbacks, first the named then the general version.
 func (f *Instance) afterEventCallbacks(machine *Machine, e *Transition) {
 	if fn, ok := machine.callbacks[callbackKey{e.Name, callbackAfterTransition}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookAfterTransition, e.Name})
 	}
 
 	if fn, ok := machine.callbacks[callbackKey{"", callbackAfterTransition}]; ok {
-		fn(e)
+		machine.runCallback(fn, e, Hook{HookAfterTransition, ""})
 	}
 }
@@ -0,0 +1,189 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Definition is the description of a machine which can be stored in a file,
+// for tools working with machines without Go code.
+//
+// A definition is written in JSON:
+//
+//	{
+//	  "name": "door",
+//	  "initial": "closed",
+//	  "transitions": [
+//	    {"name": "open", "sources": ["closed"], "destination": "open"},
+//	    {"name": "close", "sources": ["open"], "destination": "closed"}
+//	  ],
+//	  "callbacks": ["enter_open", "before_close"]
+//	}
+type Definition struct {
+	// Name is the name of the machine set with WithName.
+	Name string `json:"name,omitempty"`
+
+	// Initial is the state new instances start in.
+	Initial string `json:"initial,omitempty"`
+
+	// Transitions are the transitions of the machine.
+	Transitions []TransitionDesc `json:"transitions"`
+
+	// Callbacks are the names of the callbacks the machine is expected to be
+	// given, like enter_open. They are only used to check that they refer to
+	// existing events and states.
+	Callbacks []string `json:"callbacks,omitempty"`
+}
+
+// ParseDefinition reads a JSON definition from r. Unknown fields are rejected
+// to catch typos.
+func ParseDefinition(r io.Reader) (*Definition, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	var definition Definition
+	if err := decoder.Decode(&definition); err != nil {
+		return nil, fmt.Errorf("parsing definition: %w", err)
+	}
+
+	return &definition, nil
+}
+
+// LoadDefinition reads the JSON definition in the file at path.
+func LoadDefinition(path string) (*Definition, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	definition, err := ParseDefinition(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return definition, nil
+}
+
+// Machine returns a machine with the transitions of the definition, named
+// after it. The options are applied after WithName.
+func (d *Definition) Machine(callbacks map[string]Callback, options ...MachineOption) *Machine {
+	if d.Name != "" {
+		options = append([]MachineOption{WithName(d.Name)}, options...)
+	}
+
+	return NewMachine(d.Transitions, callbacks, options...)
+}
+
+// States returns the sorted states of the definition.
+func (d *Definition) States() []string {
+	seen := make(map[string]bool)
+	var states []string
+
+	for _, transition := range d.Transitions {
+		for _, state := range append([]string{transition.Destination}, transition.Sources...) {
+			if !seen[state] {
+				seen[state] = true
+				states = append(states, state)
+			}
+		}
+	}
+
+	sort.Strings(states)
+
+	return states
+}
+
+// Validate returns an InvalidDefinitionError for each problem of the
+// definition NewMachine would silently accept: missing names, events leading
+// to different destinations from the same state, an unknown initial state,
+// states unreachable from it and callbacks which do not refer to any event or
+// state.
+func (d *Definition) Validate() []error {
+	var errs []error
+	invalid := func(format string, args ...interface{}) {
+		errs = append(errs, InvalidDefinitionError{fmt.Sprintf(format, args...)})
+	}
+
+	if len(d.Transitions) == 0 {
+		invalid("no transitions")
+	}
+
+	allTransitions := make(map[string]bool)
+	allStates := make(map[string]bool)
+	destinations := make(map[TransitionKey]string)
+	next := make(map[string][]string)
+
+	for i, transition := range d.Transitions {
+		label := transition.Name
+		if label == "" {
+			label = fmt.Sprintf("#%d", i)
+			invalid("transition %s has no name", label)
+		}
+		if len(transition.Sources) == 0 {
+			invalid("transition %s has no source", label)
+		}
+		if transition.Destination == "" {
+			invalid("transition %s has no destination", label)
+		}
+
+		allTransitions[transition.Name] = true
+		allStates[transition.Destination] = true
+
+		for _, source := range transition.Sources {
+			if source == "" {
+				invalid("transition %s has an empty source", label)
+			}
+
+			key := TransitionKey{transition.Name, source}
+			if destination, ok := destinations[key]; ok {
+				if destination != transition.Destination {
+					invalid("event %s leads from %s to both %s and %s", transition.Name, source, destination, transition.Destination)
+				} else {
+					invalid("event %s from %s is declared twice", transition.Name, source)
+				}
+			}
+
+			destinations[key] = transition.Destination
+			allStates[source] = true
+			next[source] = append(next[source], transition.Destination)
+		}
+	}
+
+	if d.Initial != "" {
+		if !allStates[d.Initial] {
+			invalid("initial state %s is not a state of the machine", d.Initial)
+		} else {
+			reachable := map[string]bool{d.Initial: true}
+			queue := []string{d.Initial}
+			for len(queue) > 0 {
+				state := queue[0]
+				queue = queue[1:]
+
+				for _, destination := range next[state] {
+					if !reachable[destination] {
+						reachable[destination] = true
+						queue = append(queue, destination)
+					}
+				}
+			}
+
+			for _, state := range d.States() {
+				if !reachable[state] {
+					invalid("state %s is unreachable from %s", state, d.Initial)
+				}
+			}
+		}
+	}
+
+	for _, name := range d.Callbacks {
+		if _, ok := parseCallbackName(name, allTransitions, allStates); !ok {
+			invalid("callback %s does not refer to any event or state", name)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseDefinition(t *testing.T) {
+	definition, err := ParseDefinition(strings.NewReader(`{
+		"name": "door",
+		"initial": "closed",
+		"transitions": [
+			{"name": "open", "sources": ["closed"], "destination": "open"},
+			{"name": "close", "sources": ["open"], "destination": "closed"}
+		],
+		"callbacks": ["enter_open"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errs := definition.Validate(); len(errs) != 0 {
+		t.Errorf("expected a valid definition, got %v", errs)
+	}
+
+	machine := definition.Machine(map[string]Callback{})
+	if machine.Name() != "door" {
+		t.Errorf("expected machine to be named door, got %s", machine.Name())
+	}
+
+	instance := machine.NewInstance(definition.Initial)
+	if err := instance.Transition(machine, "open"); err != nil {
+		t.Errorf("expected open to succeed, got %s", err)
+	}
+
+	if _, err := ParseDefinition(strings.NewReader(`{"transitions": [], "initail": "closed"}`)); err == nil {
+		t.Error("expected unknown fields to be rejected")
+	}
+}
+
+func TestDefinitionValidate(t *testing.T) {
+	definition := Definition{
+		Initial: "closed",
+		Transitions: []TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "open", Sources: []string{"closed"}, Destination: "ajar"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+			{Name: "lock", Sources: []string{"locked"}, Destination: "closed"},
+			{Name: "", Sources: []string{"open"}, Destination: ""},
+		},
+		Callbacks: []string{"enter_open", "before_opne", "after_transition"},
+	}
+
+	var got []string
+	for _, err := range definition.Validate() {
+		got = append(got, err.Error())
+	}
+
+	wanted := []string{
+		"invalid definition: event open leads from closed to both open and ajar",
+		"invalid definition: transition #4 has no name",
+		"invalid definition: transition #4 has no destination",
+		"invalid definition: state locked is unreachable from closed",
+		"invalid definition: callback before_opne does not refer to any event or state",
+	}
+	if strings.Join(got, "\n") != strings.Join(wanted, "\n") {
+		t.Errorf("wanted \n%s\nand got \n%s", strings.Join(wanted, "\n"), strings.Join(got, "\n"))
+	}
+}
@@ -1,5 +1,10 @@
 package pkg
 
+import (
+	"fmt"
+	"strconv"
+)
+
 // InvalidEventError is returned by FSM.Event() when the event cannot be called
 // in the current state.
 type InvalidEventError struct {
@@ -20,6 +25,21 @@ func (e UnknownEventError) Error() string {
 	return "event " + e.Event + " does not exist"
 }
 
+// InvalidPayloadError is returned by Instance.Transition() when the arguments
+// of an event don't match the payload schema registered for it.
+type InvalidPayloadError struct {
+	Event string
+	Err   error
+}
+
+func (e InvalidPayloadError) Error() string {
+	return "event " + e.Event + " has an invalid payload: " + e.Err.Error()
+}
+
+func (e InvalidPayloadError) Unwrap() error {
+	return e.Err
+}
+
 // InTransitionError is returned by FSM.Event() when an asynchronous transition
 // is already in progress.
 type InTransitionError struct {
@@ -80,6 +100,101 @@ func (e AsyncError) Error() string {
 	return "async started"
 }
 
+// RaisedEventError is returned by Instance.Transition() when an event raised
+// from a callback with Instance.Raise() could not be processed.
+type RaisedEventError struct {
+	Event string
+	Err   error
+}
+
+func (e RaisedEventError) Error() string {
+	return "raised event " + e.Event + " failed: " + e.Err.Error()
+}
+
+func (e RaisedEventError) Unwrap() error {
+	return e.Err
+}
+
+// RaiseLimitError is returned by Instance.Transition() when callbacks raised
+// more events than the machine allows, which usually means they raise each
+// other in a loop.
+type RaiseLimitError struct {
+	Event string
+	Limit int
+}
+
+func (e RaiseLimitError) Error() string {
+	return "raised event " + e.Event + " exceeds the limit of " + strconv.Itoa(e.Limit) + " raised events"
+}
+
+// MailboxFullError is returned by Actor.Send() and Actor.Ask() when the
+// actor's mailbox is full and its overflow policy does not allow waiting.
+type MailboxFullError struct {
+	Event string
+}
+
+func (e MailboxFullError) Error() string {
+	return "event " + e.Event + " rejected because the mailbox is full"
+}
+
+// ActorStoppedError is returned by Actor.Send() and Actor.Ask() when the actor
+// has been stopped.
+type ActorStoppedError struct {
+	Event string
+}
+
+func (e ActorStoppedError) Error() string {
+	return "event " + e.Event + " rejected because the actor is stopped"
+}
+
+// InvalidDefinitionError is returned by Definition.Validate() for each problem
+// found in a machine definition.
+type InvalidDefinitionError struct {
+	Reason string
+}
+
+func (e InvalidDefinitionError) Error() string {
+	return "invalid definition: " + e.Reason
+}
+
+// UnregisteredMetadataError is returned by Instance.SnapshotMetadata() and
+// Instance.RestoreMetadata() for metadata with no key registered on the
+// machine.
+type UnregisteredMetadataError struct {
+	Key string
+}
+
+func (e UnregisteredMetadataError) Error() string {
+	return "metadata key " + e.Key + " is not registered"
+}
+
+// MetadataTypeError is returned when serializing a metadata value that does
+// not have the type of its key.
+type MetadataTypeError struct {
+	Key   string
+	Value interface{}
+}
+
+func (e MetadataTypeError) Error() string {
+	return fmt.Sprintf("metadata key %s cannot hold a value of type %T", e.Key, e.Value)
+}
+
+// MetadataSerializationError is returned by Instance.SnapshotMetadata() and
+// Instance.RestoreMetadata() when a metadata value cannot be serialized or
+// deserialized.
+type MetadataSerializationError struct {
+	Key string
+	Err error
+}
+
+func (e MetadataSerializationError) Error() string {
+	return "metadata key " + e.Key + " cannot be serialized: " + e.Err.Error()
+}
+
+func (e MetadataSerializationError) Unwrap() error {
+	return e.Err
+}
+
 // InternalError is returned by FSM.Event() and should never occur. It is a
 // probably because of a bug.
 type InternalError struct{}
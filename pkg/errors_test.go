@@ -76,3 +76,75 @@ func TestInternalError(t *testing.T) {
 		t.Error("InternalError string mismatch")
 	}
 }
+
+func TestRaisedEventError(t *testing.T) {
+	e := RaisedEventError{Event: "raised", Err: UnknownEventError{Event: "raised"}}
+	if e.Error() != "raised event "+e.Event+" failed: "+e.Err.Error() {
+		t.Error("RaisedEventError string mismatch")
+	}
+	if !errors.Is(e, e.Err) {
+		t.Error("RaisedEventError does not unwrap")
+	}
+}
+
+func TestRaiseLimitError(t *testing.T) {
+	e := RaiseLimitError{Event: "raised", Limit: 2}
+	if e.Error() != "raised event "+e.Event+" exceeds the limit of 2 raised events" {
+		t.Error("RaiseLimitError string mismatch")
+	}
+}
+
+func TestMailboxFullError(t *testing.T) {
+	e := MailboxFullError{Event: "full"}
+	if e.Error() != "event "+e.Event+" rejected because the mailbox is full" {
+		t.Error("MailboxFullError string mismatch")
+	}
+}
+
+func TestActorStoppedError(t *testing.T) {
+	e := ActorStoppedError{Event: "stopped"}
+	if e.Error() != "event "+e.Event+" rejected because the actor is stopped" {
+		t.Error("ActorStoppedError string mismatch")
+	}
+}
+
+func TestInvalidDefinitionError(t *testing.T) {
+	e := InvalidDefinitionError{Reason: "no transitions"}
+	if e.Error() != "invalid definition: "+e.Reason {
+		t.Error("InvalidDefinitionError string mismatch")
+	}
+}
+
+func TestUnregisteredMetadataError(t *testing.T) {
+	e := UnregisteredMetadataError{Key: "message"}
+	if e.Error() != "metadata key "+e.Key+" is not registered" {
+		t.Error("UnregisteredMetadataError string mismatch")
+	}
+}
+
+func TestMetadataTypeError(t *testing.T) {
+	e := MetadataTypeError{Key: "count", Value: "one"}
+	if e.Error() != "metadata key "+e.Key+" cannot hold a value of type string" {
+		t.Error("MetadataTypeError string mismatch")
+	}
+}
+
+func TestMetadataSerializationError(t *testing.T) {
+	e := MetadataSerializationError{Key: "message", Err: errors.New("broken")}
+	if e.Error() != "metadata key "+e.Key+" cannot be serialized: "+e.Err.Error() {
+		t.Error("MetadataSerializationError string mismatch")
+	}
+	if !errors.Is(e, e.Err) {
+		t.Error("MetadataSerializationError does not unwrap")
+	}
+}
+
+func TestInvalidPayloadError(t *testing.T) {
+	e := InvalidPayloadError{Event: "pay", Err: errors.New("negative amount")}
+	if e.Error() != "event "+e.Event+" has an invalid payload: "+e.Err.Error() {
+		t.Error("InvalidPayloadError string mismatch")
+	}
+	if !errors.Is(e, e.Err) {
+		t.Error("InvalidPayloadError does not unwrap")
+	}
+}
@@ -0,0 +1,82 @@
+package fsmtest
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// AssertPath checks that instance went through states, in order, during the
+// transitions recorded by r. It returns false and reports an error on t
+// otherwise.
+func AssertPath(t testing.TB, r *Recorder, instance *fsm.Instance, states ...string) bool {
+	t.Helper()
+
+	got := r.Path(instance)
+	if strings.Join(got, "\x00") != strings.Join(states, "\x00") || len(got) != len(states) {
+		t.Errorf("expected path [%s], got [%s]", strings.Join(states, " -> "), strings.Join(got, " -> "))
+
+		return false
+	}
+
+	return true
+}
+
+// AssertCallbackOrder checks that the callbacks named hooks, like before_open
+// or enter_state, were recorded by r in this order. Other callbacks may run
+// between them. It returns false and reports an error on t otherwise.
+func AssertCallbackOrder(t testing.TB, r *Recorder, hooks ...string) bool {
+	t.Helper()
+
+	recorded := r.Hooks()
+
+	next := 0
+	for _, hook := range recorded {
+		if next < len(hooks) && hook == hooks[next] {
+			next++
+		}
+	}
+
+	if next < len(hooks) {
+		t.Errorf("expected callbacks [%s] in order, got [%s], missing %s from position %d",
+			strings.Join(hooks, ", "), strings.Join(recorded, ", "), hooks[next], next+1)
+
+		return false
+	}
+
+	return true
+}
+
+// AssertRejected fires event on instance and checks that it fails with an
+// error of the same type as want, like fsm.InvalidEventError{}, without
+// changing the state. It returns false and reports an error on t otherwise.
+func AssertRejected(t testing.TB, machine *fsm.Machine, instance *fsm.Instance, event string, want error) bool {
+	t.Helper()
+
+	if want == nil {
+		t.Errorf("expected an error to check event %s against, got nil", event)
+
+		return false
+	}
+
+	before := instance.Current()
+	err := instance.Transition(machine, event)
+
+	target := reflect.New(reflect.TypeOf(want))
+	if err == nil || !errors.As(err, target.Interface()) {
+		t.Errorf("expected event %s to be rejected with %T, got %v", event, want, err)
+
+		return false
+	}
+
+	if after := instance.Current(); after != before {
+		t.Errorf("expected rejected event %s to keep state %s, got %s", event, before, after)
+
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,179 @@
+package fsmtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// uncoveredColor is the color of the transitions never taken in the diagram
+// of a coverage report.
+const uncoveredColor = "#FF0000"
+
+// Coverage is an Observer collecting which transitions and callbacks of a
+// machine the instances exercised, to find what a test suite never tests.
+type Coverage struct {
+	fsm.NopObserver
+
+	machine *fsm.Machine
+
+	mu          sync.Mutex
+	transitions map[fsm.TransitionKey]int
+	hooks       map[fsm.Hook]int
+}
+
+// Cover returns a coverage collector added to machine, collecting the
+// transitions and callbacks of all its instances.
+func Cover(machine *fsm.Machine) *Coverage {
+	c := &Coverage{
+		machine:     machine,
+		transitions: make(map[fsm.TransitionKey]int),
+		hooks:       make(map[fsm.Hook]int),
+	}
+	machine.AddObserver(c)
+
+	return c
+}
+
+// CallbackStarted implements fsm.Observer.
+func (c *Coverage) CallbackStarted(_ *fsm.Machine, _ *fsm.Transition, hook fsm.Hook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hooks[hook]++
+}
+
+// TransitionFinished implements fsm.Observer.
+func (c *Coverage) TransitionFinished(_ *fsm.Machine, t *fsm.Transition, err error, _ time.Duration) {
+	if outcome := fsm.Outcome(err); outcome != fsm.OutcomeOK && outcome != fsm.OutcomeNoTransition {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.transitions[fsm.TransitionKey{Event: t.Name, Src: t.Src}]++
+}
+
+// TransitionHits is the number of times a transition was taken.
+type TransitionHits struct {
+	Event string `json:"event"`
+	Src   string `json:"src"`
+	Dst   string `json:"dst"`
+	Hits  int    `json:"hits"`
+}
+
+// HookHits is the number of times a callback ran.
+type HookHits struct {
+	Hook string `json:"hook"`
+	Hits int    `json:"hits"`
+}
+
+// CoverageReport is the coverage of a machine.
+type CoverageReport struct {
+	Transitions []TransitionHits `json:"transitions"`
+	Hooks       []HookHits       `json:"hooks"`
+
+	// TransitionsCovered and HooksCovered are the number of transitions and
+	// callbacks exercised at least once.
+	TransitionsCovered int `json:"transitions_covered"`
+	HooksCovered       int `json:"hooks_covered"`
+}
+
+// Report returns the coverage collected so far, sorted by source state and
+// event, and by callback name.
+func (c *Coverage) Report() CoverageReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var report CoverageReport
+
+	for k, destination := range c.machine.Transitions() {
+		hits := c.transitions[k]
+		report.Transitions = append(report.Transitions, TransitionHits{k.Event, k.Src, destination, hits})
+		if hits > 0 {
+			report.TransitionsCovered++
+		}
+	}
+	sort.Slice(report.Transitions, func(i, j int) bool {
+		if report.Transitions[i].Src != report.Transitions[j].Src {
+			return report.Transitions[i].Src < report.Transitions[j].Src
+		}
+
+		return report.Transitions[i].Event < report.Transitions[j].Event
+	})
+
+	for _, hook := range c.machine.Hooks() {
+		hits := c.hooks[hook]
+		report.Hooks = append(report.Hooks, HookHits{hook.String(), hits})
+		if hits > 0 {
+			report.HooksCovered++
+		}
+	}
+
+	return report
+}
+
+// WriteText writes the report in a human readable form, marking what was
+// never exercised.
+func (r CoverageReport) WriteText(w io.Writer) error {
+	lines := []string{fmt.Sprintf("transitions: %d/%d covered (%s)", r.TransitionsCovered, len(r.Transitions), percent(r.TransitionsCovered, len(r.Transitions)))}
+	for _, transition := range r.Transitions {
+		lines = append(lines, fmt.Sprintf("  %s: %s -> %s: %s", transition.Event, transition.Src, transition.Dst, hits(transition.Hits)))
+	}
+
+	lines = append(lines, fmt.Sprintf("callbacks: %d/%d covered (%s)", r.HooksCovered, len(r.Hooks), percent(r.HooksCovered, len(r.Hooks))))
+	for _, hook := range r.Hooks {
+		lines = append(lines, fmt.Sprintf("  %s: %s", hook.Hook, hits(hook.Hits)))
+	}
+
+	for _, line := range lines {
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as an indented JSON object.
+func (r CoverageReport) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(r)
+}
+
+// Visualize returns the diagram of the machine in the state of instance, with
+// the transitions labeled with their hits and the uncovered ones in red.
+func (c *Coverage) Visualize(instance *fsm.Instance, visualizeType fsm.VisualizeType, options ...fsm.VisualizeOption) (string, error) {
+	c.mu.Lock()
+	heatmap := fsm.Heatmap{Transitions: make(map[fsm.TransitionKey]int, len(c.transitions)), UnusedColor: uncoveredColor}
+	for k, hits := range c.transitions {
+		heatmap.Transitions[k] = hits
+	}
+	c.mu.Unlock()
+
+	return fsm.VisualizeWithType(c.machine, instance, visualizeType, append(options, fsm.WithHeatmap(heatmap))...)
+}
+
+func hits(count int) string {
+	if count == 0 {
+		return "NOT COVERED"
+	}
+
+	return fmt.Sprintf("%d hits", count)
+}
+
+func percent(covered, total int) string {
+	if total == 0 {
+		return "100.0%"
+	}
+
+	return fmt.Sprintf("%.1f%%", float64(covered)*100/float64(total))
+}
@@ -0,0 +1,62 @@
+package fsmtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func TestCoverage(t *testing.T) {
+	machine := newDoorMachine()
+	coverage := Cover(machine)
+
+	for i := 0; i < 2; i++ {
+		instance := machine.NewInstance("closed")
+		_ = instance.Transition(machine, "open")
+		_ = instance.Transition(machine, "open")
+	}
+
+	report := coverage.Report()
+
+	var text bytes.Buffer
+	if err := report.WriteText(&text); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := `transitions: 1/2 covered (50.0%)
+  open: closed -> open: 2 hits
+  close: open -> closed: NOT COVERED
+callbacks: 4/4 covered (100.0%)
+  after_transition: 2 hits
+  before_open: 2 hits
+  enter_open: 2 hits
+  leave_closed: 2 hits
+`
+	if text.String() != wanted {
+		t.Errorf("wanted \n%s\nand got \n%s", wanted, text.String())
+	}
+
+	var js bytes.Buffer
+	if err := report.WriteJSON(&js); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(js.String(), `"event": "close",`) || !strings.Contains(js.String(), `"transitions_covered": 1,`) {
+		t.Errorf("unexpected json report \n%s", js.String())
+	}
+
+	diagram, err := coverage.Visualize(machine.NewInstance("closed"), fsm.GRAPHVIZ)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`"closed" -> "open" [ label = "open (2)", color = "#D7301F", penwidth = 5 ];`,
+		`"open" -> "closed" [ label = "close (0)", color = "#FF0000", penwidth = 1 ];`,
+	} {
+		if !strings.Contains(diagram, line) {
+			t.Errorf("expected diagram to contain %q, got \n%s", line, diagram)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package fsmtest
+
+import (
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func newRideMachine(callbacks map[string]fsm.Callback) *fsm.Machine {
+	return fsm.NewMachine(
+		[]fsm.TransitionDesc{
+			{Name: "request", Sources: []string{"idle"}, Destination: "searching"},
+			{Name: "accept", Sources: []string{"searching"}, Destination: "accepted"},
+			{Name: "cancel", Sources: []string{"searching", "accepted"}, Destination: "canceled"},
+			{Name: "retry", Sources: []string{"searching"}, Destination: "searching"},
+			{Name: "finish", Sources: []string{"accepted"}, Destination: "finished"},
+			{Name: "revive", Sources: []string{"zombie"}, Destination: "idle"},
+		},
+		callbacks,
+	)
+}
+
+func eventsOf(paths []Path) string {
+	var events []string
+	for _, path := range paths {
+		events = append(events, strings.Join(path.Events(), ","))
+	}
+
+	return strings.Join(events, " ")
+}
+
+func TestStateCoverage(t *testing.T) {
+	got := eventsOf(StateCoverage(newRideMachine(nil), "idle"))
+
+	wanted := "request,accept,finish request,cancel"
+	if got != wanted {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+}
+
+func TestTransitionCoverage(t *testing.T) {
+	got := eventsOf(TransitionCoverage(newRideMachine(nil), "idle"))
+
+	wanted := "request,accept,cancel request,accept,finish request,cancel request,retry"
+	if got != wanted {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+}
+
+func TestSwitchCoverage(t *testing.T) {
+	paths := SwitchCoverage(newRideMachine(nil), "idle", 1)
+
+	got := eventsOf(paths)
+	wanted := "request,accept,cancel request,accept,finish request,retry,accept request,retry,cancel request,retry,retry request,cancel"
+	if got != wanted {
+		t.Errorf("wanted %q, got %q", wanted, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	machine := newRideMachine(map[string]fsm.Callback{
+		"before_finish": func(t *fsm.Transition) {
+			t.Cancel()
+		},
+	})
+
+	report := Run(machine, "idle", TransitionCoverage(machine, "idle"))
+
+	if len(report.Failures) != 1 || !strings.HasPrefix(report.Failures[0].Error(), "path request,accept,finish: step 3 finish from accepted to finished: ") {
+		t.Errorf("expected finish to fail, got %v", report.Failures)
+	}
+
+	wanted := `4 paths, 1 failures, 5 transitions exercised, 2 never exercised
+failed: path request,accept,finish: step 3 finish from accepted to finished: transition canceled
+never exercised: finish from accepted
+never exercised: revive from zombie
+`
+	if got := report.String(); got != wanted {
+		t.Errorf("wanted \n%s\nand got \n%s", wanted, got)
+	}
+}
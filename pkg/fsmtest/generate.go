@@ -0,0 +1,186 @@
+// Package fsmtest helps testing machines and their callbacks.
+package fsmtest
+
+import (
+	"sort"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// Path is a sequence of transitions starting in the initial state of an
+// instance, each one starting in the destination of the previous one.
+type Path []fsm.HistoryStep
+
+// Events returns the events of the path in order.
+func (p Path) Events() []string {
+	events := make([]string, 0, len(p))
+	for _, step := range p {
+		events = append(events, step.Event)
+	}
+
+	return events
+}
+
+// graph is the transition graph of a machine with its transitions sorted to
+// generate reproducible paths.
+type graph struct {
+	transitions []fsm.HistoryStep
+	next        map[string][]fsm.HistoryStep
+
+	// shortest is the shortest path from the initial state to each reachable state.
+	shortest map[string]Path
+}
+
+func newGraph(machine *fsm.Machine, initial string) *graph {
+	g := &graph{next: make(map[string][]fsm.HistoryStep), shortest: map[string]Path{initial: nil}}
+
+	for k, destination := range machine.Transitions() {
+		step := fsm.HistoryStep{Event: k.Event, Src: k.Src, Dst: destination}
+		g.transitions = append(g.transitions, step)
+		g.next[k.Src] = append(g.next[k.Src], step)
+	}
+
+	less := func(steps []fsm.HistoryStep) func(i, j int) bool {
+		return func(i, j int) bool {
+			if steps[i].Src != steps[j].Src {
+				return steps[i].Src < steps[j].Src
+			}
+
+			return steps[i].Event < steps[j].Event
+		}
+	}
+	sort.Slice(g.transitions, less(g.transitions))
+	for _, steps := range g.next {
+		sort.Slice(steps, less(steps))
+	}
+
+	queue := []string{initial}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for _, step := range g.next[state] {
+			if _, ok := g.shortest[step.Dst]; !ok {
+				g.shortest[step.Dst] = append(append(Path(nil), g.shortest[state]...), step)
+				queue = append(queue, step.Dst)
+			}
+		}
+	}
+
+	return g
+}
+
+// pathTo returns the shortest path from the initial state taking steps at its end.
+func (g *graph) pathTo(steps ...fsm.HistoryStep) (Path, bool) {
+	prefix, ok := g.shortest[steps[0].Src]
+	if !ok {
+		return nil, false
+	}
+
+	return append(append(Path(nil), prefix...), steps...), true
+}
+
+// StateCoverage returns paths from initial which together visit every state
+// reachable from it.
+func StateCoverage(machine *fsm.Machine, initial string) []Path {
+	g := newGraph(machine, initial)
+
+	states := make([]string, 0, len(g.shortest))
+	for state := range g.shortest {
+		states = append(states, state)
+	}
+	// the farthest states first, their paths visit many of the others
+	sort.Slice(states, func(i, j int) bool {
+		if len(g.shortest[states[i]]) != len(g.shortest[states[j]]) {
+			return len(g.shortest[states[i]]) > len(g.shortest[states[j]])
+		}
+
+		return states[i] < states[j]
+	})
+
+	visited := map[string]bool{initial: true}
+	var paths []Path
+	for _, state := range states {
+		if visited[state] {
+			continue
+		}
+
+		path := g.shortest[state]
+		for _, step := range path {
+			visited[step.Dst] = true
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// TransitionCoverage returns paths from initial which together take every
+// transition whose source is reachable from it.
+func TransitionCoverage(machine *fsm.Machine, initial string) []Path {
+	return SwitchCoverage(machine, initial, 0)
+}
+
+// SwitchCoverage returns paths from initial which together take every
+// sequence of n+1 consecutive transitions whose source is reachable from it,
+// known as n-switch coverage. 0-switch coverage is transition coverage and
+// 1-switch coverage takes every pair of transitions.
+func SwitchCoverage(machine *fsm.Machine, initial string, n int) []Path {
+	g := newGraph(machine, initial)
+
+	var sequences []Path
+	var extend func(sequence Path)
+	extend = func(sequence Path) {
+		if len(sequence) == n+1 {
+			sequences = append(sequences, sequence)
+
+			return
+		}
+
+		for _, step := range g.next[sequence[len(sequence)-1].Dst] {
+			extend(append(append(Path(nil), sequence...), step))
+		}
+	}
+	for _, step := range g.transitions {
+		if _, ok := g.shortest[step.Src]; ok {
+			extend(Path{step})
+		}
+	}
+
+	candidates := make([]Path, 0, len(sequences))
+	for _, sequence := range sequences {
+		path, _ := g.pathTo(sequence...)
+		candidates = append(candidates, path)
+	}
+	// the longest paths first, they cover the sequences of the shorter ones
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i]) > len(candidates[j])
+	})
+
+	covered := make(map[string]bool)
+	var paths []Path
+	for _, path := range candidates {
+		if covered[sequenceKey(path[len(path)-n-1:])] {
+			continue
+		}
+
+		for i := 0; i+n < len(path); i++ {
+			covered[sequenceKey(path[i:i+n+1])] = true
+		}
+
+		paths = append(paths, path)
+	}
+
+	return paths
+}
+
+// sequenceKey identifies a sequence of transitions.
+func sequenceKey(sequence Path) string {
+	var key string
+	for _, step := range sequence {
+		key += step.Src + "\x00" + step.Event + "\x00"
+	}
+
+	return key
+}
@@ -0,0 +1,232 @@
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// Step is an event applied by a Harness and its outcome.
+type Step struct {
+	Event string
+	Src   string
+	Dst   string
+	Err   error
+}
+
+// Invariant is a property checked after every step applied by a Harness,
+// given the instance and every step applied to it so far.
+type Invariant struct {
+	Name  string
+	Check func(instance *fsm.Instance, steps []Step) error
+}
+
+// NeverAfter returns an invariant failing if the instance enters forbidden
+// once it has been in state, like "a refunded order never returns to paid".
+func NeverAfter(state, forbidden string) Invariant {
+	return Invariant{
+		Name: fmt.Sprintf("never %s after %s", forbidden, state),
+		Check: func(_ *fsm.Instance, steps []Step) error {
+			visited := false
+			for _, step := range steps {
+				visited = visited || step.Src == state
+				if visited && step.Dst == forbidden && step.Src != forbidden {
+					return fmt.Errorf("entered %s with %s after being in %s", forbidden, step.Event, state)
+				}
+			}
+
+			return nil
+		},
+	}
+}
+
+// Violation is an invariant failing after a sequence of events.
+type Violation struct {
+	// Invariant is the name of the invariant which failed.
+	Invariant string
+
+	// Events are the events applied, the last one breaking the invariant.
+	Events []string
+
+	// Err is the error returned by the invariant.
+	Err error
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("invariant %q broken by events [%s]: %s", v.Invariant, strings.Join(v.Events, ", "), v.Err)
+}
+
+// Harness applies sequences of events to instances of a machine, valid and
+// invalid ones, checking invariants after every step. Failing sequences are
+// shrunk to a minimal reproduction.
+//
+// Besides the given invariants, every harness checks that a successful event
+// leads to its destination and that the callbacks do not panic.
+type Harness struct {
+	machine    *fsm.Machine
+	initial    string
+	events     []string
+	invariants []Invariant
+}
+
+// NewHarness returns a harness starting each sequence in a new instance of
+// machine in the initial state. The events of the sequences are the events of
+// the machine and an unknown event.
+func NewHarness(machine *fsm.Machine, initial string, invariants ...Invariant) *Harness {
+	seen := make(map[string]bool)
+	var events []string
+	for k := range machine.Transitions() {
+		if !seen[k.Event] {
+			seen[k.Event] = true
+			events = append(events, k.Event)
+		}
+	}
+	sort.Strings(events)
+
+	return &Harness{
+		machine:    machine,
+		initial:    initial,
+		events:     append(events, "fsmtest.unknown"),
+		invariants: invariants,
+	}
+}
+
+// AddEvents adds events which are not events of the machine to the sequences.
+func (h *Harness) AddEvents(events ...string) {
+	h.events = append(h.events, events...)
+}
+
+// Events decodes a fuzzing input as a sequence of events, each byte choosing one.
+func (h *Harness) Events(data []byte) []string {
+	events := make([]string, 0, len(data))
+	for _, b := range data {
+		events = append(events, h.events[int(b)%len(h.events)])
+	}
+
+	return events
+}
+
+// Run applies events to a new instance and returns the first violation of
+// an invariant, or nil if there is none.
+func (h *Harness) Run(events []string) *Violation {
+	instance := h.machine.NewInstance(h.initial)
+	transitions := h.machine.Transitions()
+
+	var steps []Step
+	for i, event := range events {
+		step, err := h.apply(instance, event)
+		if err != nil {
+			return &Violation{"no panic", events[:i+1], err}
+		}
+		steps = append(steps, step)
+
+		if destination, ok := transitions[fsm.TransitionKey{Event: event, Src: step.Src}]; ok && step.Err == nil && step.Dst != destination {
+			return &Violation{"destination", events[:i+1], fmt.Errorf("%s led from %s to %s instead of %s", event, step.Src, step.Dst, destination)}
+		}
+
+		for _, invariant := range h.invariants {
+			if err := invariant.Check(instance, steps); err != nil {
+				return &Violation{invariant.Name, events[:i+1], err}
+			}
+		}
+	}
+
+	return nil
+}
+
+// apply fires event on instance, completing it if it is asynchronous, and
+// returns an error if a callback panics.
+func (h *Harness) apply(instance *fsm.Instance, event string) (step Step, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%s panicked: %v", event, r)
+		}
+	}()
+
+	step = Step{Event: event, Src: instance.Current()}
+	step.Err = instance.Transition(h.machine, event)
+	if errors.As(step.Err, &fsm.AsyncError{}) {
+		step.Err = instance.CompleteTransition(h.machine)
+	}
+	step.Dst = instance.Current()
+
+	return step, nil
+}
+
+// Shrink returns a shortest sequence of events breaking the same invariant as
+// v, found by removing events from it.
+func (h *Harness) Shrink(v *Violation) *Violation {
+	for chunk := len(v.Events) / 2; chunk >= 1; chunk /= 2 {
+		for i := 0; i+chunk <= len(v.Events); {
+			candidate := append(append([]string(nil), v.Events[:i]...), v.Events[i+chunk:]...)
+
+			if shrunk := h.Run(candidate); shrunk != nil && shrunk.Invariant == v.Invariant {
+				v = shrunk
+			} else {
+				i += chunk
+			}
+		}
+	}
+
+	return v
+}
+
+// Check runs the events and fails t with the shrunk violation, if any.
+func (h *Harness) Check(t testing.TB, events []string) {
+	t.Helper()
+
+	if v := h.Run(events); v != nil {
+		t.Fatal(h.Shrink(v))
+	}
+}
+
+// Fuzz adds the paths of transition coverage to the seed corpus of f and
+// fuzzes the sequences of events:
+//
+//	func FuzzOrder(f *testing.F) {
+//		fsmtest.NewHarness(machine, "created", fsmtest.NeverAfter("refunded", "paid")).Fuzz(f)
+//	}
+func (h *Harness) Fuzz(f *testing.F) {
+	index := make(map[string]byte, len(h.events))
+	for i, event := range h.events {
+		if _, ok := index[event]; !ok && i < 256 {
+			index[event] = byte(i)
+		}
+	}
+
+	for _, path := range TransitionCoverage(h.machine, h.initial) {
+		seed := make([]byte, 0, len(path))
+		for _, step := range path {
+			seed = append(seed, index[step.Event])
+		}
+
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		h.Check(t, h.Events(data))
+	})
+}
+
+// Quick runs random sequences of length events, for the property
+// tests run without fuzzing. The sequences are reproducible for a seed.
+func (h *Harness) Quick(t testing.TB, seed int64, runs, length int) {
+	t.Helper()
+
+	random := rand.New(rand.NewSource(seed))
+	for run := 0; run < runs; run++ {
+		events := make([]string, 0, length)
+		for i := 0; i < length; i++ {
+			events = append(events, h.events[random.Intn(len(h.events))])
+		}
+
+		if v := h.Run(events); v != nil {
+			t.Fatalf("run %d with seed %d: %s", run, seed, h.Shrink(v))
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package fsmtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func newOrderMachine(withBug bool, callbacks map[string]fsm.Callback) *fsm.Machine {
+	transitions := []fsm.TransitionDesc{
+		{Name: "pay", Sources: []string{"created"}, Destination: "paid"},
+		{Name: "ship", Sources: []string{"paid"}, Destination: "shipped"},
+		{Name: "refund", Sources: []string{"paid", "shipped"}, Destination: "refunded"},
+	}
+	if withBug {
+		transitions = append(transitions, fsm.TransitionDesc{Name: "retry-payment", Sources: []string{"created", "refunded"}, Destination: "paid"})
+	}
+
+	return fsm.NewMachine(transitions, callbacks)
+}
+
+func TestHarnessShrink(t *testing.T) {
+	h := NewHarness(newOrderMachine(true, nil), "created", NeverAfter("refunded", "paid"))
+
+	v := h.Run([]string{"ship", "pay", "fsmtest.unknown", "ship", "pay", "refund", "ship", "retry-payment", "ship"})
+	if v == nil {
+		t.Fatal("expected a violation")
+	}
+
+	got := h.Shrink(v).Error()
+	wanted := `invariant "never paid after refunded" broken by events [pay, refund, retry-payment]: entered paid with retry-payment after being in refunded`
+	if got != wanted {
+		t.Errorf("wanted \n%s\nand got \n%s", wanted, got)
+	}
+}
+
+func TestHarnessQuick(t *testing.T) {
+	h := NewHarness(newOrderMachine(true, nil), "created", NeverAfter("refunded", "paid"))
+
+	tb := &recordingTB{TB: t}
+	func() {
+		defer func() { _ = recover() }()
+		h.Quick(tb, 1, 100, 20)
+	}()
+
+	if !strings.Contains(tb.message, "broken by events [pay, refund, retry-payment]") {
+		t.Errorf("expected a shrunk violation, got %q", tb.message)
+	}
+
+	NewHarness(newOrderMachine(false, nil), "created", NeverAfter("refunded", "paid")).Quick(t, 1, 100, 20)
+}
+
+func TestHarnessPanic(t *testing.T) {
+	h := NewHarness(newOrderMachine(false, map[string]fsm.Callback{
+		"enter_shipped": func(*fsm.Transition) {
+			panic("no courier")
+		},
+	}), "created")
+
+	v := h.Run([]string{"refund", "pay", "ship", "refund"})
+	if v == nil || v.Error() != `invariant "no panic" broken by events [refund, pay, ship]: ship panicked: no courier` {
+		t.Errorf("expected the panic to be reported, got %v", v)
+	}
+}
+
+func FuzzHarness(f *testing.F) {
+	NewHarness(newOrderMachine(false, nil), "created", NeverAfter("refunded", "paid")).Fuzz(f)
+}
+
+// recordingTB records the message of Fatal instead of failing the test.
+type recordingTB struct {
+	testing.TB
+	message string
+}
+
+func (tb *recordingTB) Helper() {}
+
+func (tb *recordingTB) Fatalf(format string, args ...interface{}) {
+	tb.message = fmt.Sprintf(format, args...)
+	panic(tb)
+}
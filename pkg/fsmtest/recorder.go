@@ -0,0 +1,138 @@
+package fsmtest
+
+import (
+	"sync"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// Invocation is a callback run recorded by a Recorder.
+type Invocation struct {
+	// Order is the position of the invocation among all the recorded ones,
+	// starting at 1.
+	Order int
+
+	// Hook identifies the callback.
+	Hook fsm.Hook
+
+	Instance *fsm.Instance
+	Event    string
+	Src      string
+	Dst      string
+	Args     []interface{}
+}
+
+// TransitionRecord is a processed event recorded by a Recorder.
+type TransitionRecord struct {
+	Instance *fsm.Instance
+	Event    string
+	Src      string
+	Dst      string
+	Err      error
+}
+
+// Recorder is an Observer capturing every callback invocation and processed
+// event of the machines it is added to, for assertions in tests instead of
+// closures appending to slices.
+type Recorder struct {
+	fsm.NopObserver
+
+	mu          sync.Mutex
+	invocations []Invocation
+	transitions []TransitionRecord
+}
+
+// NewRecorder returns a recorder to be added to machines with WithObserver or
+// Machine.AddObserver.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Record returns a recorder added to machine.
+func Record(machine *fsm.Machine) *Recorder {
+	r := NewRecorder()
+	machine.AddObserver(r)
+
+	return r
+}
+
+// CallbackStarted implements fsm.Observer.
+func (r *Recorder) CallbackStarted(_ *fsm.Machine, t *fsm.Transition, hook fsm.Hook) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invocations = append(r.invocations, Invocation{
+		Order:    len(r.invocations) + 1,
+		Hook:     hook,
+		Instance: t.Instance,
+		Event:    t.Name,
+		Src:      t.Src,
+		Dst:      t.Dst,
+		Args:     append([]interface{}(nil), t.Args...),
+	})
+}
+
+// TransitionFinished implements fsm.Observer.
+func (r *Recorder) TransitionFinished(_ *fsm.Machine, t *fsm.Transition, err error, _ time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.transitions = append(r.transitions, TransitionRecord{t.Instance, t.Name, t.Src, t.Dst, err})
+}
+
+// Invocations returns the recorded callback invocations in order.
+func (r *Recorder) Invocations() []Invocation {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Invocation(nil), r.invocations...)
+}
+
+// Transitions returns the recorded events in the order they finished,
+// including the rejected ones.
+func (r *Recorder) Transitions() []TransitionRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]TransitionRecord(nil), r.transitions...)
+}
+
+// Hooks returns the names of the recorded callbacks in order, like
+// before_open.
+func (r *Recorder) Hooks() []string {
+	invocations := r.Invocations()
+
+	hooks := make([]string, 0, len(invocations))
+	for _, invocation := range invocations {
+		hooks = append(hooks, invocation.Hook.String())
+	}
+
+	return hooks
+}
+
+// Path returns the states instance went through during the recorded
+// transitions which changed its state.
+func (r *Recorder) Path(instance *fsm.Instance) []string {
+	var states []string
+	for _, record := range r.Transitions() {
+		if record.Instance != instance || fsm.Outcome(record.Err) != fsm.OutcomeOK {
+			continue
+		}
+
+		if len(states) == 0 {
+			states = append(states, record.Src)
+		}
+		states = append(states, record.Dst)
+	}
+
+	return states
+}
+
+// Reset discards everything recorded so far.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.invocations, r.transitions = nil, nil
+}
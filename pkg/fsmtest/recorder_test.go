@@ -0,0 +1,103 @@
+package fsmtest
+
+import (
+	"fmt"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// failingTB records the errors reported instead of failing the test.
+type failingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (tb *failingTB) Helper() {}
+
+func (tb *failingTB) Errorf(format string, args ...interface{}) {
+	tb.errors = append(tb.errors, fmt.Sprintf(format, args...))
+}
+
+func newDoorMachine() *fsm.Machine {
+	nop := func(*fsm.Transition) {}
+
+	return fsm.NewMachine(
+		[]fsm.TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]fsm.Callback{
+			"before_open":      nop,
+			"leave_closed":     nop,
+			"enter_open":       nop,
+			"after_transition": nop,
+		},
+	)
+}
+
+func TestRecorder(t *testing.T) {
+	machine := newDoorMachine()
+	r := Record(machine)
+	instance := machine.NewInstance("closed")
+
+	if err := instance.Transition(machine, "open", "key"); err != nil {
+		t.Fatal(err)
+	}
+	_ = instance.Transition(machine, "open")
+	if err := instance.Transition(machine, "close"); err != nil {
+		t.Fatal(err)
+	}
+
+	invocations := r.Invocations()
+	if len(invocations) != 5 {
+		t.Fatalf("expected 5 invocations, got %v", invocations)
+	}
+
+	first := invocations[0]
+	if first.Order != 1 || first.Hook.String() != "before_open" || first.Src != "closed" || first.Dst != "open" || first.Args[0] != "key" || first.Instance != instance {
+		t.Errorf("unexpected first invocation %+v", first)
+	}
+
+	AssertPath(t, r, instance, "closed", "open", "closed")
+	AssertCallbackOrder(t, r, "before_open", "enter_open", "after_transition")
+	AssertRejected(t, machine, instance, "close", fsm.InvalidEventError{})
+	AssertRejected(t, machine, instance, "lock", fsm.UnknownEventError{})
+
+	r.Reset()
+	if len(r.Invocations()) != 0 || len(r.Transitions()) != 0 {
+		t.Error("expected nothing recorded after reset")
+	}
+}
+
+func TestAssertionsFailures(t *testing.T) {
+	machine := newDoorMachine()
+	r := Record(machine)
+	instance := machine.NewInstance("closed")
+	_ = instance.Transition(machine, "open")
+
+	tb := &failingTB{TB: t}
+
+	if AssertPath(tb, r, instance, "closed", "ajar") {
+		t.Error("expected AssertPath to fail")
+	}
+	if AssertCallbackOrder(tb, r, "enter_open", "before_open") {
+		t.Error("expected AssertCallbackOrder to fail")
+	}
+	if AssertRejected(tb, machine, instance, "close", fsm.InvalidEventError{}) {
+		t.Error("expected AssertRejected to fail")
+	}
+	if AssertRejected(tb, machine, instance, "close", nil) {
+		t.Error("expected AssertRejected to fail without an error")
+	}
+
+	wanted := []string{
+		"expected path [closed -> ajar], got [closed -> open]",
+		"expected callbacks [enter_open, before_open] in order, got [before_open, leave_closed, enter_open, after_transition], missing before_open from position 2",
+		"expected event close to be rejected with pkg.InvalidEventError, got <nil>",
+		"expected an error to check event close against, got nil",
+	}
+	if fmt.Sprint(tb.errors) != fmt.Sprint(wanted) {
+		t.Errorf("wanted \n%v\nand got \n%v", wanted, tb.errors)
+	}
+}
@@ -0,0 +1,129 @@
+package fsmtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// Failure is a step of a path which did not behave as the transitions of the
+// machine describe, the rest of the path is not run.
+type Failure struct {
+	Path Path
+	Step int
+	Err  error
+}
+
+func (f Failure) Error() string {
+	step := f.Path[f.Step]
+
+	return fmt.Sprintf("path %s: step %d %s from %s to %s: %s",
+		strings.Join(f.Path.Events(), ","), f.Step+1, step.Event, step.Src, step.Dst, f.Err)
+}
+
+// Report is the result of running paths against a machine.
+type Report struct {
+	// Paths is the number of paths run.
+	Paths int
+
+	// Failures are the steps which failed, at most one per path.
+	Failures []Failure
+
+	// Exercised is the number of times each transition was taken.
+	Exercised map[fsm.TransitionKey]int
+
+	// Unexercised are the transitions of the machine never taken, because
+	// no path tried them or because their callbacks canceled them.
+	Unexercised []fsm.TransitionKey
+}
+
+// String returns a summary of the report listing the failures and the
+// transitions never taken.
+func (r *Report) String() string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("%d paths, %d failures, %d transitions exercised, %d never exercised\n",
+		r.Paths, len(r.Failures), len(r.Exercised), len(r.Unexercised)))
+	for _, failure := range r.Failures {
+		builder.WriteString(fmt.Sprintf("failed: %s\n", failure.Error()))
+	}
+	for _, k := range r.Unexercised {
+		builder.WriteString(fmt.Sprintf("never exercised: %s from %s\n", k.Event, k.Src))
+	}
+
+	return builder.String()
+}
+
+// Run runs each path against a fresh instance of machine in the initial
+// state. A step fails if the transition returns an error, after completing
+// it if it is asynchronous, or ends in another state than its destination.
+func Run(machine *fsm.Machine, initial string, paths []Path) *Report {
+	report := &Report{Paths: len(paths), Exercised: make(map[fsm.TransitionKey]int)}
+
+	for _, path := range paths {
+		instance := machine.NewInstance(initial)
+
+		for i, step := range path {
+			if err := runStep(machine, instance, step); err != nil {
+				report.Failures = append(report.Failures, Failure{path, i, err})
+
+				break
+			}
+
+			report.Exercised[fsm.TransitionKey{Event: step.Event, Src: step.Src}]++
+		}
+	}
+
+	for k := range machine.Transitions() {
+		if report.Exercised[k] == 0 {
+			report.Unexercised = append(report.Unexercised, k)
+		}
+	}
+	sort.Slice(report.Unexercised, func(i, j int) bool {
+		if report.Unexercised[i].Src != report.Unexercised[j].Src {
+			return report.Unexercised[i].Src < report.Unexercised[j].Src
+		}
+
+		return report.Unexercised[i].Event < report.Unexercised[j].Event
+	})
+
+	return report
+}
+
+func runStep(machine *fsm.Machine, instance *fsm.Instance, step fsm.HistoryStep) error {
+	if current := instance.Current(); current != step.Src {
+		return fmt.Errorf("instance is in state %s", current)
+	}
+
+	err := instance.Transition(machine, step.Event)
+	if errors.As(err, &fsm.AsyncError{}) {
+		err = instance.CompleteTransition(machine)
+	}
+
+	var noTransition fsm.NoTransitionError
+	if err != nil && !(errors.As(err, &noTransition) && noTransition.Err == nil && step.Src == step.Dst) {
+		return err
+	}
+
+	if current := instance.Current(); current != step.Dst {
+		return fmt.Errorf("instance ended in state %s", current)
+	}
+
+	return nil
+}
+
+// Check runs the paths like Run and reports each failure as an error of t.
+func Check(t testing.TB, machine *fsm.Machine, initial string, paths []Path) *Report {
+	t.Helper()
+
+	report := Run(machine, initial, paths)
+	for _, failure := range report.Failures {
+		t.Error(failure)
+	}
+
+	return report
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// Heatmap is the usage of a machine aggregated over many instances, to be
+// visualized with WithHeatmap.
+type Heatmap struct {
+	// Transitions is the number of times each transition was taken.
+	Transitions map[TransitionKey]int
+
+	// States is the number of instances in each state, e.g. as returned by
+	// Registry.CountByState. The states are not weighted if it is nil.
+	States map[string]int
+
+	// UnusedColor is the color of the transitions never taken, gray by default.
+	UnusedColor string
+}
+
+// TransitionCounter is an Observer counting the successful transitions of all
+// the instances of the machines it is added to.
+//
+// It has to be created with NewTransitionCounter and added to machines with
+// WithObserver or Machine.AddObserver.
+type TransitionCounter struct {
+	NopObserver
+
+	mu     sync.Mutex
+	counts map[TransitionKey]int
+}
+
+// NewTransitionCounter returns a counter without any transition counted.
+func NewTransitionCounter() *TransitionCounter {
+	return &TransitionCounter{counts: make(map[TransitionKey]int)}
+}
+
+// Counts returns a copy of the number of times each transition was taken.
+func (c *TransitionCounter) Counts() map[TransitionKey]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[TransitionKey]int, len(c.counts))
+	for k, count := range c.counts {
+		counts[k] = count
+	}
+
+	return counts
+}
+
+// TransitionFinished implements Observer.
+func (c *TransitionCounter) TransitionFinished(_ *Machine, t *Transition, err error, _ time.Duration) {
+	if outcome := Outcome(err); outcome != OutcomeOK && outcome != OutcomeNoTransition {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.counts[TransitionKey{t.Name, t.Src}]++
+}
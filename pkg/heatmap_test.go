@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTransitionCounter(t *testing.T) {
+	counter := NewTransitionCounter()
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]Callback{},
+		WithObserver(counter),
+	)
+
+	for _, initial := range []string{"closed", "closed", "open"} {
+		i := machineUnderTest.NewInstance(initial)
+		_ = i.Transition(machineUnderTest, "open")
+	}
+
+	counts := counter.Counts()
+	if got := counts[TransitionKey{"open", "closed"}]; got != 2 {
+		t.Errorf("expected 2 open transitions from closed, got %d", got)
+	}
+	if got := counts[TransitionKey{"open", "open"}]; got != 0 {
+		t.Errorf("expected rejected transitions not to be counted, got %d", got)
+	}
+}
+
+func TestVisualizeWithHeatmap(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("ride.finished")
+	heatmap := Heatmap{
+		Transitions: map[TransitionKey]int{
+			{"pay", "ride.finished"}:       100,
+			{"confirm", "payment.pending"}: 60,
+			{"retry", "payment.pending"}:   20,
+		},
+		States: map[string]int{"ride.finished": 5, "payment.pending": 10},
+	}
+
+	got := Visualize(machineUnderTest, i, WithHeatmap(heatmap))
+	wanted := `digraph fsm {
+    "ride.finished" -> "payment.pending" [ label = "pay (100)", color = "#D7301F", penwidth = 5 ];
+    "payment.pending" -> "payment.paid" [ label = "confirm (60)", color = "#EA825E", penwidth = 3 ];
+    "payment.pending" -> "payment.pending" [ label = "retry (20)", color = "#FDD49E", penwidth = 1 ];
+
+    "payment.paid" [ label = "payment.paid (0)" ];
+    "payment.pending" [ label = "payment.pending (10)", style = "filled", fillcolor = "#D7301F" ];
+    "ride.finished" [ label = "ride.finished (5)", style = "filled", fillcolor = "#FDD49E" ];
+    graph [ label = "heatmap\ltransitions: 20 (#FDD49E, width 1) to 100 (#D7301F, width 5)\loccupancy: 5 (#FDD49E) to 10 (#D7301F)\l", labelloc = "b", labeljust = "l" ];
+}
+`
+	if got != wanted {
+		t.Errorf("build graphivz graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+
+	mermaid, err := VisualizeWithType(machineUnderTest, i, MermaidFlowChart, WithHeatmap(heatmap))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`title: "heatmap | transitions: 20 (#FDD49E, width 1) to 100 (#D7301F, width 5) | occupancy: 5 (#FDD49E) to 10 (#D7301F)"`,
+		`id2 --> |pay #40;100#41;| id1`,
+		`linkStyle 0 stroke:#EA825E,stroke-width:3px`,
+		`style id1 fill:#D7301F`,
+	} {
+		if !strings.Contains(mermaid, line) {
+			t.Errorf("expected mermaid diagram to contain %q, got \n%s", line, mermaid)
+		}
+	}
+}
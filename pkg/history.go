@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"sync"
+	"time"
+)
+
+// HistoryStep is a transition taken by an instance.
+type HistoryStep struct {
+	// Event is the name of the event that caused the transition.
+	Event string
+
+	// Src is the state before the transition.
+	Src string
+
+	// Dst is the state after the transition.
+	Dst string
+}
+
+// HistoryRecorder is an Observer recording the successful transitions of each
+// instance, to be visualized with WithHistory.
+//
+// It has to be created with NewHistoryRecorder and added to machines with
+// WithObserver or Machine.AddObserver.
+type HistoryRecorder struct {
+	NopObserver
+
+	// limit is the maximum number of steps kept per instance.
+	limit int
+
+	mu      sync.Mutex
+	history map[*Instance][]HistoryStep
+}
+
+// NewHistoryRecorder returns a recorder keeping the last limit steps of each
+// instance, or all of them if limit is lower than one.
+func NewHistoryRecorder(limit int) *HistoryRecorder {
+	return &HistoryRecorder{
+		limit:   limit,
+		history: make(map[*Instance][]HistoryStep),
+	}
+}
+
+// History returns the steps recorded for instance, oldest first.
+func (r *HistoryRecorder) History(instance *Instance) []HistoryStep {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]HistoryStep(nil), r.history[instance]...)
+}
+
+// TransitionFinished implements Observer.
+func (r *HistoryRecorder) TransitionFinished(_ *Machine, t *Transition, err error, _ time.Duration) {
+	if outcome := Outcome(err); outcome != OutcomeOK && outcome != OutcomeNoTransition {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	steps := append(r.history[t.Instance], HistoryStep{t.Name, t.Src, t.Dst})
+	if r.limit > 0 && len(steps) > r.limit {
+		steps = steps[len(steps)-r.limit:]
+	}
+
+	r.history[t.Instance] = steps
+}
+
+// InstanceRemoved implements Observer.
+func (r *HistoryRecorder) InstanceRemoved(_ *Machine, instance *Instance) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.history, instance)
+}
@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHistoryRecorder(t *testing.T) {
+	recorder := NewHistoryRecorder(2)
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]Callback{},
+		WithObserver(recorder),
+	)
+	i := machineUnderTest.NewInstance("closed")
+
+	for _, event := range []string{"open", "close", "close", "open"} {
+		_ = i.Transition(machineUnderTest, event)
+	}
+
+	got := recorder.History(i)
+	wanted := []HistoryStep{{"close", "open", "closed"}, {"open", "closed", "open"}}
+	if len(got) != len(wanted) || got[0] != wanted[0] || got[1] != wanted[1] {
+		t.Errorf("expected history %v, got %v", wanted, got)
+	}
+
+	machineUnderTest.instanceRemoved(i)
+	if got := recorder.History(i); len(got) != 0 {
+		t.Errorf("expected no history after removal, got %v", got)
+	}
+}
+
+func TestVisualizeWithHistory(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("payment.paid")
+	history := []HistoryStep{
+		{"pay", "ride.finished", "payment.pending"},
+		{"retry", "payment.pending", "payment.pending"},
+		{"retry", "payment.pending", "payment.pending"},
+		{"confirm", "payment.pending", "payment.paid"},
+	}
+
+	got := Visualize(machineUnderTest, i, WithHistory(history))
+	wanted := `digraph fsm {
+    "payment.pending" -> "payment.paid" [ label = "4. confirm", color = "#8A2BE2", penwidth = 2 ];
+    "payment.pending" -> "payment.pending" [ label = "2, 3. retry", color = "#8A2BE2", penwidth = 2 ];
+    "ride.finished" -> "payment.pending" [ label = "1. pay", color = "#8A2BE2", penwidth = 2 ];
+
+    "payment.paid" [ label = "payment.paid (1)", style = "filled", fillcolor = "#00AA00" ];
+    "payment.pending" [ label = "payment.pending (3)" ];
+    "ride.finished" [ label = "ride.finished (1)" ];
+}
+`
+	if got != wanted {
+		t.Errorf("build graphivz graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+
+	mermaid, err := VisualizeWithType(machineUnderTest, i, MermaidStateDiagram, WithHistory(history), WithHistoryColor("#123456"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{
+		`state "payment.pending #40;3#41;" as id1`,
+		`[*] --> id0`,
+		`id1 --> id1: 2, 3. retry`,
+		`id2 --> id1: 1. pay`,
+	} {
+		if !strings.Contains(mermaid, line) {
+			t.Errorf("expected mermaid diagram to contain %q, got \n%s", line, mermaid)
+		}
+	}
+}
@@ -3,9 +3,23 @@ package pkg
 import (
 	"errors"
 	"sync"
+	"time"
 )
 
+// InstanceOption configures optional behaviour of an Instance.
+type InstanceOption func(*Instance)
+
+// WithInstanceID sets the ID identifying the instance in logs.
+func WithInstanceID(id string) InstanceOption {
+	return func(instance *Instance) {
+		instance.id = id
+	}
+}
+
 type Instance struct {
+	// id identifies the instance in logs.
+	id string
+
 	// current is the state that the FSM is currently in.
 	current string
 
@@ -14,16 +28,54 @@ type Instance struct {
 	transition func()
 	// transitionerObj calls the FSM's transition() function.
 	transitionerObj transitioner
+	// pending is the asynchronous transition waiting for CompleteTransition().
+	pending *Transition
 
 	// stateMu guards access to the current state.
 	stateMu sync.RWMutex
 	// eventMu guards access to Event() and Transition().
 	eventMu sync.Mutex
 	// metadata can be used to store and load data that maybe used across events
-	// use methods SetMetadata() and Metadata() to store and load data
+	// use methods SetMetadata() and GetMetadata(), or a typed Key, to store
+	// and load data
 	metadata map[string]interface{}
+	// metadataEntries holds the scope and expiration of the metadata values
+	// that have one.
+	metadataEntries map[string]metadataEntry
+	// metadataHook is called after each change of the metadata.
+	metadataHook func(MetadataChange)
+	// staging is the view of the running transition the metadata methods go
+	// through while it can be canceled.
+	staging *StagedMetadata
+	// now reads the current time for metadata expirations.
+	now func() time.Time
 
 	metadataMu sync.RWMutex
+
+	// raised holds the events raised by callbacks that are waiting to be
+	// processed once the current transition completes.
+	raised []raisedEvent
+	// held holds the events raised by the callbacks of a pending asynchronous
+	// transition until it is completed.
+	held []raisedEvent
+	// processing is set while Transition() is running and Raise() is allowed.
+	processing bool
+	// correlation is shared by the transitions of a Transition() call and
+	// the events raised during it.
+	correlation *correlation
+	// raisedMu guards access to raised and processing.
+	raisedMu sync.Mutex
+}
+
+// raisedEvent is an event queued with Raise().
+type raisedEvent struct {
+	name string
+	args []interface{}
+}
+
+// ID returns the ID of the instance set with WithInstanceID.
+func (f *Instance) ID() string {
+	return f.id
 }
 
 // Current returns the current state of the FSM.
@@ -44,11 +96,16 @@ func (f *Instance) Is(state string) bool {
 
 // SetState allows the user to move to the given state from current state.
 // The call does not trigger any callbacks, if defined.
+// Metadata scoped to the previous state is removed.
 func (f *Instance) SetState(state string) {
 	f.stateMu.Lock()
-	defer f.stateMu.Unlock()
-
+	previous := f.current
 	f.current = state
+	f.stateMu.Unlock()
+
+	if previous != state {
+		f.exitMetadataState(previous)
+	}
 }
 
 // Can returns true if event can occur in the current state.
@@ -56,7 +113,7 @@ func (f *Instance) Can(machine *Machine, event string) bool {
 	f.stateMu.RLock()
 	defer f.stateMu.RUnlock()
 
-	_, ok := machine.transitions[transitionKey{event, f.current}]
+	_, ok := machine.transitions[TransitionKey{event, f.current}]
 
 	return ok && (f.transition == nil)
 }
@@ -68,29 +125,117 @@ func (f *Instance) AvailableTransitions(machine *Machine) []string {
 
 	var transitions []string
 	for key := range machine.transitions {
-		if key.source == f.current {
-			transitions = append(transitions, key.name)
+		if key.Src == f.current {
+			transitions = append(transitions, key.Event)
 		}
 	}
 
 	return transitions
 }
 
-// SetMetadata stores the dataValue in metadata indexing it with key.
-func (f *Instance) SetMetadata(key string, dataValue interface{}) {
-	f.metadataMu.Lock()
-	defer f.metadataMu.Unlock()
-	f.metadata[key] = dataValue
+// SetMetadata stores the dataValue in metadata indexing it with key. The
+// options scope the value to a state or make it expire, replacing those of
+// the value previously stored with key.
+//
+// Like the other metadata methods, it goes through the view of the running
+// transition from its before_ callbacks until it can no longer be canceled,
+// see Transition.Metadata.
+func (f *Instance) SetMetadata(key string, dataValue interface{}, options ...MetadataOption) {
+	if staging := f.stagingMetadata(); staging != nil {
+		staging.SetMetadata(key, dataValue, options...)
+
+		return
+	}
+
+	f.setMetadata(key, dataValue, options)
+}
+
+// DeleteMetadata removes the value stored in metadata with key.
+func (f *Instance) DeleteMetadata(key string) {
+	if staging := f.stagingMetadata(); staging != nil {
+		staging.DeleteMetadata(key)
+
+		return
+	}
+
+	f.deleteMetadata(key)
+}
+
+// UpdateMetadata atomically replaces the value stored in metadata with key by
+// the result of update, called with the current value, and returns it. The
+// value keeps its scope and expiration. The metadata must not be accessed from
+// update.
+func (f *Instance) UpdateMetadata(key string, update func(dataValue interface{}, ok bool) interface{}) interface{} {
+	if staging := f.stagingMetadata(); staging != nil {
+		return staging.UpdateMetadata(key, update)
+	}
+
+	return f.updateMetadata(key, update)
 }
 
-// GetMetadata returns the value stored in metadata.
+// GetMetadata returns the value stored in metadata, unless it expired.
 func (f *Instance) GetMetadata(key string) (interface{}, bool) {
+	if staging := f.stagingMetadata(); staging != nil {
+		return staging.GetMetadata(key)
+	}
+
+	return f.getMetadata(key)
+}
+
+func (f *Instance) setMetadata(key string, dataValue interface{}, options []MetadataOption) {
+	entry := f.newMetadataEntry(options)
+
+	f.metadataMu.Lock()
+	change := f.storeMetadata(key, dataValue, &entry)
+	f.metadataMu.Unlock()
+
+	f.notifyMetadata(change)
+}
+
+func (f *Instance) deleteMetadata(key string) {
+	f.metadataMu.Lock()
+	change, ok := f.removeMetadata(key, MetadataDeleted)
+	f.metadataMu.Unlock()
+
+	if ok {
+		f.notifyMetadata(change)
+	}
+}
+
+func (f *Instance) updateMetadata(key string, update func(dataValue interface{}, ok bool) interface{}) interface{} {
+	f.metadataMu.Lock()
+	dataValue, ok := f.lookupMetadata(key)
+	dataValue = update(dataValue, ok)
+	change := f.storeMetadata(key, dataValue, nil)
+	f.metadataMu.Unlock()
+
+	f.notifyMetadata(change)
+
+	return dataValue
+}
+
+func (f *Instance) getMetadata(key string) (interface{}, bool) {
+	f.metadataMu.RLock()
+	defer f.metadataMu.RUnlock()
+
+	return f.lookupMetadata(key)
+}
+
+// stagingMetadata returns the view of the running transition the metadata
+// methods go through, if any.
+func (f *Instance) stagingMetadata() *StagedMetadata {
 	f.metadataMu.RLock()
 	defer f.metadataMu.RUnlock()
 
-	dataElement, ok := f.metadata[key]
+	return f.staging
+}
+
+// setStagingMetadata sets the view the metadata methods go through.
+func (f *Instance) setStagingMetadata(staging *StagedMetadata) {
+	f.metadataMu.Lock()
+	defer f.metadataMu.Unlock()
 
-	return dataElement, ok
+	f.staging = staging
 }
 
 // Transition initiates a state transition with the named event.
@@ -106,25 +251,184 @@ func (f *Instance) GetMetadata(key string) (interface{}, bool) {
 //
 // - event X does not exist
 //
+// - event X has an invalid payload
+//
 // - internal error on state transition
 //
 // The last error should never occur in this situation and is a sign of an
 // internal bug.
+//
+// Events raised by callbacks with Raise are processed in order once the
+// transition completes. They are dropped if the transition is canceled and
+// held until CompleteTransition if it is asynchronous. The failure of a
+// raised event is returned as RaisedEventError, joined to the error of the
+// named event if any, and the events raised after it are dropped.
 func (f *Instance) Transition(machine *Machine, name string, args ...interface{}) error {
 	f.eventMu.Lock()
 	defer f.eventMu.Unlock()
 
+	return f.runToCompletion(machine, func() error {
+		return f.transitionStep(machine, name, args)
+	})
+}
+
+// CompleteTransition completes an asynchronous state transition, started by a
+// leave_<STATE> callback calling Async on its transition, running the enter_
+// and after_ callbacks.
+//
+// It returns NotInTransitionError if no asynchronous transition is pending,
+// otherwise the error set on the transition by its callbacks, if any. Events
+// raised by the callbacks are processed as in Transition.
+func (f *Instance) CompleteTransition(machine *Machine) error {
+	f.eventMu.Lock()
+	defer f.eventMu.Unlock()
+
+	return f.runToCompletion(machine, func() error {
+		return f.completeStep(machine)
+	})
+}
+
+// runToCompletion runs step followed by the events raised while running it.
+// The raised events are dropped when step is canceled and held until
+// CompleteTransition when it starts an asynchronous transition.
+func (f *Instance) runToCompletion(machine *Machine, step func() error) error {
+	f.startProcessing()
+	defer f.stopProcessing()
+
+	err := step()
+
+	if errors.As(err, new(CanceledError)) {
+		return err
+	} else if errors.As(err, new(AsyncError)) {
+		f.holdRaised()
+
+		return err
+	}
+
+	raisedErr := f.processRaised(machine)
+	if raisedErr == nil {
+		return err
+	} else if err == nil {
+		return raisedErr
+	}
+
+	return errors.Join(err, raisedErr)
+}
+
+// Raise queues the named event to be processed after the current transition
+// completes, giving callbacks run-to-completion semantics. Calling Transition
+// from a callback deadlocks, so callbacks should use Raise instead.
+//
+// It returns NotInTransitionError when no call to Transition is running.
+func (f *Instance) Raise(name string, args ...interface{}) error {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	if !f.processing {
+		return NotInTransitionError{}
+	}
+
+	f.raised = append(f.raised, raisedEvent{name, args})
+
+	return nil
+}
+
+func (f *Instance) startProcessing() {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	f.processing = true
+	f.correlation = &correlation{}
+}
+
+// holdRaised keeps the raised events for the completion of the pending
+// asynchronous transition.
+func (f *Instance) holdRaised() {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	f.held, f.raised = f.raised, nil
+}
+
+// resumeHeld queues the events held by the pending asynchronous transition
+// before those raised while completing it, which all share the correlation
+// the transition started with.
+func (f *Instance) resumeHeld(correlation *correlation) {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	f.raised, f.held = append(f.held, f.raised...), nil
+	f.correlation = correlation
+}
+
+func (f *Instance) stopProcessing() {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	f.processing = false
+	f.raised = nil
+}
+
+// nextRaised pops the oldest raised event from the queue.
+func (f *Instance) nextRaised() (raisedEvent, bool) {
+	f.raisedMu.Lock()
+	defer f.raisedMu.Unlock()
+
+	if len(f.raised) == 0 {
+		return raisedEvent{}, false
+	}
+
+	event := f.raised[0]
+	f.raised = f.raised[1:]
+
+	return event, true
+}
+
+// processRaised runs the raised events until the queue is empty, one of them
+// fails or the machine's raise limit is reached.
+func (f *Instance) processRaised(machine *Machine) error {
+	for count := 0; ; count++ {
+		event, ok := f.nextRaised()
+		if !ok {
+			return nil
+		}
+
+		if count >= machine.maxRaiseChain {
+			return RaiseLimitError{event.name, machine.maxRaiseChain}
+		}
+
+		err := f.transitionStep(machine, event.name, event.args)
+		if err != nil && !errors.As(err, new(NoTransitionError)) {
+			return RaisedEventError{event.name, err}
+		}
+	}
+}
+
+// transitionStep performs a single transition for the named event.
+func (f *Instance) transitionStep(machine *Machine, name string, args []interface{}) (err error) {
 	f.stateMu.RLock()
 	defer f.stateMu.RUnlock()
 
+	e := &Transition{Instance: f, Name: name, Src: f.current, Args: args, correlation: f.correlation}
+	e.metadata = &StagedMetadata{instance: f, staged: make(map[string]stagedValue)}
+	dst, ok := machine.transitions[TransitionKey{name, f.current}]
+	if ok {
+		e.Dst = dst
+	}
+
+	start := time.Now()
+	machine.transitionStarted(e)
+	defer func() {
+		machine.transitionFinished(e, err, time.Since(start))
+	}()
+
 	if f.transition != nil {
 		return InTransitionError{name}
 	}
 
-	dst, ok := machine.transitions[transitionKey{name, f.current}]
 	if !ok {
 		for transitionkey := range machine.transitions {
-			if transitionkey.name == name {
+			if transitionkey.Event == name {
 				return InvalidEventError{name, f.current}
 			}
 		}
@@ -132,14 +436,22 @@ func (f *Instance) Transition(machine *Machine, name string, args ...interface{}
 		return UnknownEventError{name}
 	}
 
-	e := &Transition{f, name, f.current, dst, nil, args, false, false}
+	if err = machine.validatePayload(e); err != nil {
+		return err
+	}
 
-	err := f.beforeEventCallbacks(machine, e)
+	// Stage the metadata written by the callbacks until the transition can no
+	// longer be canceled.
+	f.setStagingMetadata(e.metadata)
+	defer f.setStagingMetadata(nil)
+
+	err = f.beforeEventCallbacks(machine, e)
 	if err != nil {
 		return err
 	}
 
 	if f.current == dst {
+		e.metadata.commit()
 		f.afterEventCallbacks(machine, e)
 
 		return NoTransitionError{e.Err}
@@ -147,10 +459,14 @@ func (f *Instance) Transition(machine *Machine, name string, args ...interface{}
 
 	// Setup the transition, call it later.
 	f.transition = func() {
+		e.metadata.commit()
+
 		f.stateMu.Lock()
 		f.current = dst
 		f.stateMu.Unlock()
 
+		f.exitMetadataState(e.Src)
+
 		f.enterStateCallbacks(machine, e)
 		f.afterEventCallbacks(machine, e)
 	}
@@ -158,6 +474,11 @@ func (f *Instance) Transition(machine *Machine, name string, args ...interface{}
 	if err = f.leaveStateCallbacks(machine, e); err != nil {
 		if ok := errors.As(err, new(CanceledError)); ok {
 			f.transition = nil
+		} else if ok := errors.As(err, new(AsyncError)); ok {
+			// The transition can no longer be canceled, and the metadata may be
+			// written directly until it completes.
+			e.metadata.commit()
+			f.pending = e
 		}
 
 		return err
@@ -174,6 +495,29 @@ func (f *Instance) Transition(machine *Machine, name string, args ...interface{}
 	return e.Err
 }
 
+// completeStep performs the rest of the pending asynchronous transition.
+func (f *Instance) completeStep(machine *Machine) (err error) {
+	e := f.pending
+	if e == nil {
+		return NotInTransitionError{}
+	}
+
+	start := time.Now()
+	machine.transitionStarted(e)
+	defer func() {
+		machine.transitionFinished(e, err, time.Since(start))
+	}()
+
+	f.pending = nil
+	f.resumeHeld(e.correlation)
+
+	if err := f.doTransition(); err != nil {
+		return InternalError{}
+	}
+
+	return e.Err
+}
+
 // doTransition wraps transitioner.transition.
 func (f *Instance) doTransition() error {
 	return f.transitionerObj.transition(f)
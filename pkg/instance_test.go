@@ -1 +1,298 @@
 package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRaiseRunsAfterCurrentTransition(t *testing.T) {
+	var order []string
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+			{Name: "start", Sources: []string{"accepted"}, Destination: "started"},
+		},
+		map[string]Callback{
+			"enter_accepted": func(t *Transition) {
+				order = append(order, "enter_accepted")
+				if err := t.Instance.Raise("start"); err != nil {
+					order = append(order, err.Error())
+				}
+			},
+			"after_accept": func(t *Transition) {
+				order = append(order, "after_accept")
+			},
+			"enter_started": func(t *Transition) {
+				order = append(order, "enter_started:"+t.Src)
+			},
+		},
+	)
+
+	instance := machine.NewInstance("offering")
+
+	if err := instance.Transition(machine, "accept"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if instance.Current() != "started" {
+		t.Errorf("expected state to be started, got %s", instance.Current())
+	}
+
+	expected := []string{"enter_accepted", "after_accept", "enter_started:accepted"}
+	if len(order) != len(expected) {
+		t.Fatalf("expected callbacks %v, got %v", expected, order)
+	}
+
+	for i := range expected {
+		if order[i] != expected[i] {
+			t.Fatalf("expected callbacks %v, got %v", expected, order)
+		}
+	}
+}
+
+func TestRaiseOutsideTransition(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{},
+	)
+
+	instance := machine.NewInstance("closed")
+
+	if err := instance.Raise("open"); !errors.As(err, new(NotInTransitionError)) {
+		t.Errorf("expected NotInTransitionError, got %v", err)
+	}
+}
+
+func TestRaiseReturnsRaisedEventError(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]Callback{
+			"enter_open": func(t *Transition) {
+				_ = t.Instance.Raise("open")
+				_ = t.Instance.Raise("close")
+			},
+		},
+	)
+
+	instance := machine.NewInstance("closed")
+
+	err := instance.Transition(machine, "open")
+
+	var raisedErr RaisedEventError
+	if !errors.As(err, &raisedErr) {
+		t.Fatalf("expected RaisedEventError, got %v", err)
+	}
+
+	if raisedErr.Event != "open" || !errors.As(err, new(InvalidEventError)) {
+		t.Errorf("expected raised open to be invalid, got %v", err)
+	}
+
+	if instance.Current() != "open" {
+		t.Errorf("expected events after the failed one to be dropped, got state %s", instance.Current())
+	}
+}
+
+func TestRaiseLimit(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "toggle", Sources: []string{"on"}, Destination: "off"},
+			{Name: "toggle", Sources: []string{"off"}, Destination: "on"},
+		},
+		map[string]Callback{
+			"after_toggle": func(t *Transition) {
+				_ = t.Instance.Raise("toggle")
+			},
+		},
+		WithMaxRaiseChain(3),
+	)
+
+	instance := machine.NewInstance("on")
+
+	err := instance.Transition(machine, "toggle")
+
+	var limitErr RaiseLimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != 3 {
+		t.Fatalf("expected RaiseLimitError with limit 3, got %v", err)
+	}
+
+	if instance.Current() != "on" {
+		t.Errorf("expected three raised toggles to be processed, got state %s", instance.Current())
+	}
+
+	// the queue must be empty for the next transition
+	err = instance.Transition(machine, "toggle")
+	if !errors.As(err, new(RaiseLimitError)) {
+		t.Errorf("expected RaiseLimitError again, got %v", err)
+	}
+}
+
+func TestRaiseFromSelfTransition(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "ping", Sources: []string{"idle"}, Destination: "idle"},
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+		},
+		map[string]Callback{
+			"ping": func(t *Transition) {
+				_ = t.Instance.Raise("start")
+			},
+		},
+	)
+
+	instance := machine.NewInstance("idle")
+
+	err := instance.Transition(machine, "ping")
+	if !errors.As(err, new(NoTransitionError)) {
+		t.Errorf("expected NoTransitionError, got %v", err)
+	}
+
+	if instance.Current() != "running" {
+		t.Errorf("expected state to be running, got %s", instance.Current())
+	}
+}
+
+func TestCompleteTransition(t *testing.T) {
+	var entered []string
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+			{Name: "stop", Sources: []string{"running"}, Destination: "idle"},
+		},
+		map[string]Callback{
+			"leave_idle": func(t *Transition) {
+				t.Async()
+			},
+			"enter_running": func(t *Transition) {
+				entered = append(entered, t.Dst)
+				_ = t.Instance.Raise("stop")
+			},
+		},
+	)
+
+	instance := machine.NewInstance("idle")
+
+	if err := instance.CompleteTransition(machine); !errors.As(err, new(NotInTransitionError)) {
+		t.Errorf("expected NotInTransitionError, got %v", err)
+	}
+
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(AsyncError)) {
+		t.Fatalf("expected AsyncError, got %v", err)
+	}
+
+	if instance.Current() != "idle" || len(entered) != 0 {
+		t.Fatalf("expected the transition to be on hold in idle")
+	}
+
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(InTransitionError)) {
+		t.Errorf("expected InTransitionError, got %v", err)
+	}
+
+	if err := instance.CompleteTransition(machine); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if len(entered) != 1 || instance.Current() != "idle" {
+		t.Errorf("expected running to be entered and the raised stop processed, got state %s", instance.Current())
+	}
+
+	if err := instance.CompleteTransition(machine); !errors.As(err, new(NotInTransitionError)) {
+		t.Errorf("expected NotInTransitionError, got %v", err)
+	}
+}
+
+func TestRaiseDroppedWhenCanceled(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+			{Name: "cancel", Sources: []string{"idle"}, Destination: "canceled"},
+		},
+		map[string]Callback{
+			"before_start": func(t *Transition) {
+				_ = t.Instance.Raise("cancel")
+				t.Cancel()
+			},
+		},
+	)
+
+	instance := machine.NewInstance("idle")
+
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(CanceledError)) {
+		t.Errorf("expected CanceledError, got %v", err)
+	}
+
+	if instance.Current() != "idle" {
+		t.Errorf("expected the raised event to be dropped, got state %s", instance.Current())
+	}
+}
+
+func TestRaiseHeldUntilCompleteTransition(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+			{Name: "stop", Sources: []string{"running"}, Destination: "stopped"},
+		},
+		map[string]Callback{
+			"leave_idle": func(t *Transition) {
+				_ = t.Instance.Raise("stop")
+				t.Async()
+			},
+		},
+	)
+
+	instance := machine.NewInstance("idle")
+
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(AsyncError)) {
+		t.Fatalf("expected AsyncError, got %v", err)
+	}
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(InTransitionError)) {
+		t.Fatalf("expected InTransitionError, got %v", err)
+	}
+	if instance.Current() != "idle" {
+		t.Fatalf("expected the raised event to be held, got state %s", instance.Current())
+	}
+
+	if err := instance.CompleteTransition(machine); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if instance.Current() != "stopped" {
+		t.Errorf("expected the held event to run after the completion, got state %s", instance.Current())
+	}
+}
+
+func TestRaisedErrorJoined(t *testing.T) {
+	failure := errors.New("failure")
+	pay := NewPayload[int]("pay")
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "order", Sources: []string{"idle"}, Destination: "ordered"},
+			{Name: "pay", Sources: []string{"ordered"}, Destination: "paid"},
+		},
+		map[string]Callback{
+			"after_order": func(t *Transition) {
+				_ = t.Instance.Raise("pay", "ten")
+				t.Err = failure
+			},
+		},
+		WithPayloads(pay),
+	)
+
+	instance := machine.NewInstance("idle")
+
+	err := instance.Transition(machine, "order")
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the error of the event, got %v", err)
+	}
+
+	var raisedErr RaisedEventError
+	if !errors.As(err, &raisedErr) || raisedErr.Event != "pay" || !errors.As(err, new(InvalidPayloadError)) {
+		t.Errorf("expected the invalid payload of the raised event, got %v", err)
+	}
+}
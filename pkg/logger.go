@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// WithLogger makes the machine log the lifecycle of its transitions to logger.
+//
+// Successful transitions, cancellations and asynchronous starts and
+// completions are logged at info level, rejected events at warn level,
+// internal errors at error level and callbacks at debug level.
+func WithLogger(logger *slog.Logger) MachineOption {
+	return WithObserver(&loggingObserver{logger: logger})
+}
+
+// loggingObserver is an Observer writing structured log records.
+type loggingObserver struct {
+	NopObserver
+
+	logger *slog.Logger
+}
+
+func (o *loggingObserver) InstanceCreated(machine *Machine, instance *Instance) {
+	o.logger.LogAttrs(context.Background(), slog.LevelDebug, "instance created",
+		slog.String("machine", machine.Name()),
+		slog.String("instance_id", instance.ID()),
+		slog.String("state", instance.Current()),
+	)
+}
+
+func (o *loggingObserver) TransitionStarted(machine *Machine, t *Transition) {
+	message := "transition started"
+	if t.IsAsync() {
+		message = "async transition completing"
+	}
+
+	o.logger.LogAttrs(context.Background(), slog.LevelDebug, message, transitionAttrs(machine, t)...)
+}
+
+func (o *loggingObserver) CallbackStarted(machine *Machine, t *Transition, hook Hook) {
+	o.logger.LogAttrs(context.Background(), slog.LevelDebug, "callback started",
+		append(transitionAttrs(machine, t), slog.String("callback", hook.String()))...,
+	)
+}
+
+func (o *loggingObserver) CallbackFinished(machine *Machine, t *Transition, hook Hook, duration time.Duration) {
+	o.logger.LogAttrs(context.Background(), slog.LevelDebug, "callback finished",
+		append(transitionAttrs(machine, t), slog.String("callback", hook.String()), slog.Duration("duration", duration))...,
+	)
+}
+
+func (o *loggingObserver) TransitionFinished(machine *Machine, t *Transition, err error, duration time.Duration) {
+	outcome := Outcome(err)
+
+	level := slog.LevelInfo
+	message := "transition completed"
+
+	switch outcome {
+	case OutcomeNoTransition:
+		message = "transition completed without state change"
+	case OutcomeCanceled:
+		message = "transition canceled"
+	case OutcomeAsync:
+		message = "async transition started"
+	case OutcomeInTransition, OutcomeInvalidEvent, OutcomeUnknownEvent, OutcomeInvalidPayload:
+		level = slog.LevelWarn
+		message = "event rejected"
+	case OutcomeError:
+		level = slog.LevelError
+		message = "transition failed"
+	}
+
+	if outcome == OutcomeOK && t.IsAsync() {
+		message = "async transition completed"
+	}
+
+	attrs := append(transitionAttrs(machine, t), slog.String("outcome", outcome), slog.Duration("duration", duration))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+
+	o.logger.LogAttrs(context.Background(), level, message, attrs...)
+}
+
+// transitionAttrs returns the attributes identifying a transition.
+func transitionAttrs(machine *Machine, t *Transition) []slog.Attr {
+	return []slog.Attr{
+		slog.String("machine", machine.Name()),
+		slog.String("instance_id", t.Instance.ID()),
+		slog.String("event", t.Name),
+		slog.String("src", t.Src),
+		slog.String("dst", t.Dst),
+		slog.String("correlation_id", t.CorrelationID()),
+	}
+}
+
+// correlation is the correlation ID shared by the transitions of a call to
+// Instance.Transition. The ID is only generated when first asked for, so that
+// transitions nobody observes don't read random bytes.
+type correlation struct {
+	once sync.Once
+	id   string
+}
+
+// ID returns the correlation ID, generating it on the first call.
+func (c *correlation) ID() string {
+	c.once.Do(func() {
+		c.id = newCorrelationID()
+	})
+
+	return c.id
+}
+
+// newCorrelationID returns a random ID for correlating transitions.
+func newCorrelationID() string {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+			{Name: "start", Sources: []string{"accepted"}, Destination: "started"},
+		},
+		map[string]Callback{
+			"enter_accepted": func(t *Transition) {
+				_ = t.Instance.Raise("start")
+			},
+		},
+		WithName("ride"),
+		WithLogger(logger),
+	)
+
+	instance := machine.NewInstance("offering", WithInstanceID("ride-1"))
+
+	_ = instance.Transition(machine, "accept")
+	_ = instance.Transition(machine, "accept")
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		record := make(map[string]interface{})
+		if err := decoder.Decode(&record); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		records = append(records, record)
+	}
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	accept, start, rejected := records[0], records[1], records[2]
+
+	expected := map[string]interface{}{
+		"level":       "INFO",
+		"msg":         "transition completed",
+		"machine":     "ride",
+		"instance_id": "ride-1",
+		"event":       "accept",
+		"src":         "offering",
+		"dst":         "accepted",
+		"outcome":     OutcomeOK,
+	}
+	for key, value := range expected {
+		if accept[key] != value {
+			t.Errorf("expected %s to be %v, got %v", key, value, accept[key])
+		}
+	}
+
+	if start["event"] != "start" || start["correlation_id"] != accept["correlation_id"] {
+		t.Errorf("expected the raised event to share the correlation ID, got %v", start)
+	}
+
+	if rejected["level"] != "WARN" || rejected["msg"] != "event rejected" || rejected["error"] == nil {
+		t.Errorf("expected the invalid event to be logged as rejected, got %v", rejected)
+	}
+
+	if rejected["correlation_id"] == accept["correlation_id"] {
+		t.Errorf("expected a new correlation ID for a new transition")
+	}
+}
+
+func TestCorrelationIDIsLazy(t *testing.T) {
+	var transitions []*Transition
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+			{Name: "start", Sources: []string{"accepted"}, Destination: "started"},
+		},
+		map[string]Callback{
+			"after_transition": func(t *Transition) {
+				transitions = append(transitions, t)
+				if t.Name == "accept" {
+					_ = t.Instance.Raise("start")
+				}
+			},
+		},
+	)
+
+	if err := machine.NewInstance("offering").Transition(machine, "accept"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(transitions) != 2 || transitions[0].correlation.id != "" {
+		t.Fatalf("expected no correlation ID to be generated until asked for")
+	}
+
+	id := transitions[1].CorrelationID()
+	if len(id) != 16 || transitions[0].CorrelationID() != id {
+		t.Errorf("expected the raised event to share the correlation ID %q, got %q", id, transitions[0].CorrelationID())
+	}
+}
+
+func TestCorrelationIDAcrossCompleteTransition(t *testing.T) {
+	ids := make(map[string]string)
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+			{Name: "stop", Sources: []string{"running"}, Destination: "stopped"},
+		},
+		map[string]Callback{
+			"leave_idle": func(t *Transition) {
+				_ = t.Instance.Raise("stop")
+				t.Async()
+			},
+			"after_transition": func(t *Transition) {
+				ids[t.Name] = t.CorrelationID()
+			},
+		},
+	)
+
+	instance := machine.NewInstance("idle")
+	if err := instance.Transition(machine, "start"); !errors.As(err, new(AsyncError)) {
+		t.Fatalf("expected AsyncError, got %v", err)
+	}
+	if err := instance.CompleteTransition(machine); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids["start"] == "" || ids["stop"] != ids["start"] {
+		t.Errorf("expected the held event to share the correlation ID of the completed transition, got %v", ids)
+	}
+}
@@ -1,22 +1,77 @@
 package pkg
 
-import "strings"
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRaiseChain is the default number of events that can be raised
+// from callbacks while processing a single call to Instance.Transition.
+const DefaultMaxRaiseChain = 64
 
 // Machine is the state machine descriptor that holds the blueprint of the FSM.
 //
 // It has to be created with NewMachine to function properly.
 type Machine struct {
+	// name identifies the machine in metrics, traces and logs.
+	name string
+
 	// transitions maps source states via a transition to destination states.
-	transitions map[transitionKey]string
+	transitions map[TransitionKey]string
 
 	// callbacks maps events and targets to callback functions.
 	callbacks map[callbackKey]Callback
+
+	// maxRaiseChain limits the number of raised events processed after a
+	// single transition, to catch callbacks raising events in a loop.
+	maxRaiseChain int
+
+	// pureCallbacks are the before_ callbacks that Instance.Simulate runs.
+	pureCallbacks map[callbackKey]bool
+	// pureNames are the names given to WithPureCallbacks.
+	pureNames map[string]bool
+
+	// payloads are the payload schemas of the events, by event name.
+	payloads map[string]PayloadSchema
+
+	// metadataKeys are the keys used to snapshot and restore metadata, by name.
+	metadataKeys map[string]MetadataKey
+
+	// observers are notified by the transition pipeline.
+	observers []Observer
+	// observersMu guards access to observers.
+	observersMu sync.RWMutex
+}
+
+// MachineOption configures optional behaviour of a Machine.
+type MachineOption func(*Machine)
+
+// WithMaxRaiseChain sets the maximum number of events that may be raised with
+// Instance.Raise while processing a single call to Instance.Transition.
+// With a limit lower than one every raised event fails with RaiseLimitError.
+func WithMaxRaiseChain(limit int) MachineOption {
+	return func(machine *Machine) {
+		machine.maxRaiseChain = limit
+	}
+}
+
+// WithName sets the name identifying the machine in metrics, traces and logs.
+func WithName(name string) MachineOption {
+	return func(machine *Machine) {
+		machine.name = name
+	}
 }
 
-func NewMachine(transitions []TransitionDesc, callbacks map[string]Callback) *Machine {
+func NewMachine(transitions []TransitionDesc, callbacks map[string]Callback, options ...MachineOption) *Machine {
 	machine := &Machine{
-		transitions: make(map[transitionKey]string),
-		callbacks:   make(map[callbackKey]Callback),
+		transitions:   make(map[TransitionKey]string),
+		callbacks:     make(map[callbackKey]Callback),
+		maxRaiseChain: DefaultMaxRaiseChain,
+	}
+
+	for _, option := range options {
+		option(machine)
 	}
 
 	// Build transition map and store sets of all events and states.
@@ -24,8 +79,8 @@ func NewMachine(transitions []TransitionDesc, callbacks map[string]Callback) *Ma
 	allStates := make(map[string]bool)
 	for _, transition := range transitions {
 		for _, source := range transition.Sources {
-			transitionKey := transitionKey{transition.Name, source}
-			machine.transitions[transitionKey] = transition.Destination
+			key := TransitionKey{transition.Name, source}
+			machine.transitions[key] = transition.Destination
 			allStates[source] = true
 			allStates[transition.Destination] = true
 		}
@@ -34,63 +89,119 @@ func NewMachine(transitions []TransitionDesc, callbacks map[string]Callback) *Ma
 
 	// Map all callbacks to transitions/states.
 	for name, callback := range callbacks {
-		callbackType := callbackNone
-		var target string
-
-		switch {
-		case strings.HasPrefix(name, "before_"):
-			target = strings.TrimPrefix(name, "before_")
-			if target == "transition" {
-				target = ""
-				callbackType = callbackBeforeTransition
-			} else if _, ok := allTransitions[target]; ok {
-				callbackType = callbackBeforeTransition
-			}
-		case strings.HasPrefix(name, "leave_"):
-			target = strings.TrimPrefix(name, "leave_")
-			if target == "state" {
-				target = ""
-				callbackType = callbackLeaveState
-			} else if _, ok := allStates[target]; ok {
-				callbackType = callbackLeaveState
-			}
-		case strings.HasPrefix(name, "enter_"):
-			target = strings.TrimPrefix(name, "enter_")
-			if target == "state" {
-				target = ""
-				callbackType = callbackEnterState
-			} else if _, ok := allStates[target]; ok {
-				callbackType = callbackEnterState
-			}
-		case strings.HasPrefix(name, "after_"):
-			target = strings.TrimPrefix(name, "after_")
-			if target == "transition" {
-				target = ""
-				callbackType = callbackAfterTransition
-			} else if _, ok := allTransitions[target]; ok {
-				callbackType = callbackAfterTransition
-			}
-		default:
-			target = name
-			if _, ok := allStates[target]; ok {
-				callbackType = callbackEnterState
-			} else if _, ok := allTransitions[target]; ok {
-				callbackType = callbackAfterTransition
+		if key, ok := parseCallbackName(name, allTransitions, allStates); ok {
+			machine.callbacks[key] = callback
+
+			if machine.pureNames[name] && key.callbackType == callbackBeforeTransition {
+				if machine.pureCallbacks == nil {
+					machine.pureCallbacks = make(map[callbackKey]bool)
+				}
+				machine.pureCallbacks[key] = true
 			}
 		}
+	}
+
+	return machine
+}
+
+// parseCallbackName returns the key of the callback registered as name, or
+// false if name does not refer to any of the events and states.
+func parseCallbackName(name string, allTransitions, allStates map[string]bool) (callbackKey, bool) {
+	callbackType := callbackNone
+	var target string
 
-		if callbackType != callbackNone {
-			machine.callbacks[callbackKey{target, callbackType}] = callback
+	switch {
+	case strings.HasPrefix(name, "before_"):
+		target = strings.TrimPrefix(name, "before_")
+		if target == "transition" {
+			target = ""
+			callbackType = callbackBeforeTransition
+		} else if _, ok := allTransitions[target]; ok {
+			callbackType = callbackBeforeTransition
+		}
+	case strings.HasPrefix(name, "leave_"):
+		target = strings.TrimPrefix(name, "leave_")
+		if target == "state" {
+			target = ""
+			callbackType = callbackLeaveState
+		} else if _, ok := allStates[target]; ok {
+			callbackType = callbackLeaveState
+		}
+	case strings.HasPrefix(name, "enter_"):
+		target = strings.TrimPrefix(name, "enter_")
+		if target == "state" {
+			target = ""
+			callbackType = callbackEnterState
+		} else if _, ok := allStates[target]; ok {
+			callbackType = callbackEnterState
+		}
+	case strings.HasPrefix(name, "after_"):
+		target = strings.TrimPrefix(name, "after_")
+		if target == "transition" {
+			target = ""
+			callbackType = callbackAfterTransition
+		} else if _, ok := allTransitions[target]; ok {
+			callbackType = callbackAfterTransition
+		}
+	default:
+		target = name
+		if _, ok := allStates[target]; ok {
+			callbackType = callbackEnterState
+		} else if _, ok := allTransitions[target]; ok {
+			callbackType = callbackAfterTransition
 		}
 	}
 
-	return machine
+	return callbackKey{target, callbackType}, callbackType != callbackNone
+}
+
+// Name returns the name of the machine set with WithName.
+func (machine *Machine) Name() string {
+	return machine.name
+}
+
+// Transitions returns a copy of the transitions of the machine, the
+// destination of each event in each of its source states.
+func (machine *Machine) Transitions() map[TransitionKey]string {
+	transitions := make(map[TransitionKey]string, len(machine.transitions))
+	for k, destination := range machine.transitions {
+		transitions[k] = destination
+	}
+
+	return transitions
+}
+
+func (machine *Machine) NewInstance(initial string, options ...InstanceOption) *Instance {
+	instance := machine.newInstance(initial, options...)
+	machine.instanceCreated(instance)
+
+	return instance
 }
 
-func (machine *Machine) NewInstance(initial string) *Instance {
-	return &Instance{
+// newInstance returns a new instance without notifying the observers.
+func (machine *Machine) newInstance(initial string, options ...InstanceOption) *Instance {
+	instance := &Instance{
 		current:         initial,
 		transitionerObj: &transitionerStruct{},
 		metadata:        make(map[string]interface{}),
+		metadataEntries: make(map[string]metadataEntry),
+		now:             time.Now,
+	}
+
+	for _, option := range options {
+		option(instance)
 	}
+
+	return instance
+}
+
+// isFinal returns true if no transition leaves the given state.
+func (machine *Machine) isFinal(state string) bool {
+	for key := range machine.transitions {
+		if key.Src == state {
+			return false
+		}
+	}
+
+	return true
 }
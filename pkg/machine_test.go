@@ -1 +1,66 @@
 package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMachineIsFinal(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "break", Sources: []string{"open", "closed"}, Destination: "broken"},
+		},
+		map[string]Callback{},
+	)
+
+	if machine.isFinal("closed") || machine.isFinal("open") {
+		t.Error("expected states with outgoing transitions not to be final")
+	}
+
+	if !machine.isFinal("broken") {
+		t.Error("expected broken to be final")
+	}
+}
+
+func TestMachineTransitions(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "break", Sources: []string{"open", "closed"}, Destination: "broken"},
+		},
+		map[string]Callback{},
+	)
+
+	transitions := machine.Transitions()
+	if len(transitions) != 2 || transitions[TransitionKey{"break", "open"}] != "broken" {
+		t.Errorf("unexpected transitions %v", transitions)
+	}
+
+	delete(transitions, TransitionKey{"break", "open"})
+	if !machine.NewInstance("open").Can(machine, "break") {
+		t.Error("expected the transitions to be a copy")
+	}
+}
+
+func TestMachineHooks(t *testing.T) {
+	nop := func(*Transition) {}
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{
+			"leave_closed":     nop,
+			"before_open":      nop,
+			"after_transition": nop,
+		},
+	)
+
+	var names []string
+	for _, hook := range machine.Hooks() {
+		names = append(names, hook.String())
+	}
+
+	if strings.Join(names, ",") != "after_transition,before_open,leave_closed" {
+		t.Errorf("unexpected hooks %v", names)
+	}
+}
@@ -0,0 +1,382 @@
+package pkg
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// MetadataStore stores the metadata of an instance. It is implemented by
+// Instance and by StagedMetadata, the view of a transition on the metadata.
+type MetadataStore interface {
+	GetMetadata(key string) (interface{}, bool)
+	SetMetadata(key string, value interface{}, options ...MetadataOption)
+	DeleteMetadata(key string)
+	UpdateMetadata(key string, update func(value interface{}, ok bool) interface{}) interface{}
+}
+
+// MetadataKey is a metadata key able to serialize its values, so the metadata
+// of an instance can be snapshotted and restored.
+type MetadataKey interface {
+	// Name returns the name the values are stored under in the metadata.
+	Name() string
+	// MarshalMetadata serializes a value stored under the key.
+	MarshalMetadata(value interface{}) ([]byte, error)
+	// UnmarshalMetadata deserializes a value serialized by MarshalMetadata.
+	UnmarshalMetadata(data []byte) (interface{}, error)
+}
+
+// WithMetadataKeys registers the keys used to snapshot and restore the
+// metadata of the machine's instances.
+func WithMetadataKeys(keys ...MetadataKey) MachineOption {
+	return func(machine *Machine) {
+		if machine.metadataKeys == nil {
+			machine.metadataKeys = make(map[string]MetadataKey, len(keys))
+		}
+
+		for _, key := range keys {
+			machine.metadataKeys[key.Name()] = key
+		}
+	}
+}
+
+// Key is a metadata key holding values of type T, sparing callers the type
+// assertions of GetMetadata.
+//
+// Keys are namespaced, so that modules using the same name for their own
+// data don't overwrite each other. Values are serialized as JSON unless the
+// key is given a codec with WithCodec.
+type Key[T any] struct {
+	name      string
+	marshal   func(T) ([]byte, error)
+	unmarshal func([]byte) (T, error)
+}
+
+// NewKey returns the key named name in namespace. Keys in the empty namespace
+// share the metadata with SetMetadata and GetMetadata, the others are stored
+// as "namespace/name".
+func NewKey[T any](namespace, name string) Key[T] {
+	if namespace != "" {
+		name = namespace + "/" + name
+	}
+
+	return Key[T]{name: name}
+}
+
+// WithCodec returns a copy of the key serializing its values with marshal and
+// unmarshal instead of JSON.
+func (k Key[T]) WithCodec(marshal func(T) ([]byte, error), unmarshal func([]byte) (T, error)) Key[T] {
+	k.marshal = marshal
+	k.unmarshal = unmarshal
+
+	return k
+}
+
+// Name returns the name the values are stored under in the metadata.
+func (k Key[T]) Name() string {
+	return k.name
+}
+
+// Get returns the value stored under the key. It returns false when no value
+// is stored or the stored value is not a T.
+func (k Key[T]) Get(store MetadataStore) (T, bool) {
+	value, _ := store.GetMetadata(k.name)
+	typed, ok := value.(T)
+
+	return typed, ok
+}
+
+// Set stores value under the key, with the lifecycle set by options.
+func (k Key[T]) Set(store MetadataStore, value T, options ...MetadataOption) {
+	store.SetMetadata(k.name, value, options...)
+}
+
+// Delete removes the value stored under the key.
+func (k Key[T]) Delete(store MetadataStore) {
+	store.DeleteMetadata(k.name)
+}
+
+// Update atomically replaces the value stored under the key by the result of
+// update, called with the current value as returned by Get, and returns it.
+// The metadata must not be accessed from update.
+func (k Key[T]) Update(store MetadataStore, update func(value T, ok bool) T) T {
+	value := store.UpdateMetadata(k.name, func(value interface{}, _ bool) interface{} {
+		typed, ok := value.(T)
+
+		return update(typed, ok)
+	})
+
+	typed, _ := value.(T)
+
+	return typed
+}
+
+// MarshalMetadata implements MetadataKey.
+func (k Key[T]) MarshalMetadata(value interface{}) ([]byte, error) {
+	typed, ok := value.(T)
+	if !ok {
+		return nil, MetadataTypeError{k.name, value}
+	}
+
+	if k.marshal != nil {
+		return k.marshal(typed)
+	}
+
+	return json.Marshal(typed)
+}
+
+// UnmarshalMetadata implements MetadataKey.
+func (k Key[T]) UnmarshalMetadata(data []byte) (interface{}, error) {
+	if k.unmarshal != nil {
+		return k.unmarshal(data)
+	}
+
+	var value T
+	err := json.Unmarshal(data, &value)
+
+	return value, err
+}
+
+// MetadataSnapshot is the serialized metadata of an instance by key name.
+type MetadataSnapshot map[string]MetadataValue
+
+// MetadataValue is a serialized metadata value with its lifecycle.
+type MetadataValue struct {
+	// Data is the value serialized by its key.
+	Data json.RawMessage `json:"data"`
+
+	// State is the state the value is scoped to, if any.
+	State string `json:"state,omitempty"`
+
+	// Expires is when the value expires, if ever.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+// SnapshotMetadata serializes the metadata of the instance with the keys
+// registered on the machine with WithMetadataKeys. Expired values are left
+// out.
+//
+// It returns UnregisteredMetadataError for values stored under a name with no
+// registered key, so that no metadata is silently lost.
+func (f *Instance) SnapshotMetadata(machine *Machine) (MetadataSnapshot, error) {
+	f.metadataMu.RLock()
+	defer f.metadataMu.RUnlock()
+
+	snapshot := make(MetadataSnapshot, len(f.metadata))
+	for name := range f.metadata {
+		value, ok := f.lookupMetadata(name)
+		if !ok {
+			continue
+		}
+
+		key, ok := machine.metadataKeys[name]
+		if !ok {
+			return nil, UnregisteredMetadataError{name}
+		}
+
+		data, err := key.MarshalMetadata(value)
+		if err != nil {
+			return nil, MetadataSerializationError{name, err}
+		}
+
+		entry := f.metadataEntries[name]
+		snapshotValue := MetadataValue{Data: data, State: entry.state}
+		if !entry.expires.IsZero() {
+			expires := entry.expires
+			snapshotValue.Expires = &expires
+		}
+
+		snapshot[name] = snapshotValue
+	}
+
+	return snapshot, nil
+}
+
+// RestoreMetadata replaces the metadata of the instance by the values of
+// snapshot, deserialized with the keys registered on the machine, without
+// notifying the metadata hook. The metadata is left untouched when an error
+// is returned.
+func (f *Instance) RestoreMetadata(machine *Machine, snapshot MetadataSnapshot) error {
+	metadata := make(map[string]interface{}, len(snapshot))
+	entries := make(map[string]metadataEntry)
+	for name, snapshotValue := range snapshot {
+		key, ok := machine.metadataKeys[name]
+		if !ok {
+			return UnregisteredMetadataError{name}
+		}
+
+		value, err := key.UnmarshalMetadata(snapshotValue.Data)
+		if err != nil {
+			return MetadataSerializationError{name, err}
+		}
+
+		metadata[name] = value
+
+		entry := metadataEntry{state: snapshotValue.State}
+		if snapshotValue.Expires != nil {
+			entry.expires = *snapshotValue.Expires
+		}
+		if entry != (metadataEntry{}) {
+			entries[name] = entry
+		}
+	}
+
+	f.metadataMu.Lock()
+	defer f.metadataMu.Unlock()
+
+	f.metadata = metadata
+	f.metadataEntries = entries
+
+	return nil
+}
+
+// StagedMetadata is the view of a transition on the metadata of its instance.
+// Writes are staged until the transition can no longer be canceled, that is
+// once its leave_ callbacks succeeded or made it asynchronous, and dropped if
+// it is canceled. Once the writes are committed the view writes through to the
+// instance.
+//
+// While the writes are staged, the metadata methods of the instance go
+// through the view too, so callbacks see their own writes and other readers
+// of the instance may see writes that are discarded later.
+type StagedMetadata struct {
+	instance *Instance
+
+	// staged holds the values written before the commit, by key.
+	staged map[string]stagedValue
+	// committed is set once the staged values are written to the instance.
+	committed bool
+
+	mu sync.Mutex
+}
+
+// stagedValue is a value written to the metadata during a transition.
+type stagedValue struct {
+	value   interface{}
+	deleted bool
+
+	// entry is the lifecycle of the value, nil to keep the one of the value
+	// stored in the instance.
+	entry *metadataEntry
+}
+
+// GetMetadata returns the value staged with key or else stored in the metadata
+// of the instance, unless it expired.
+func (m *StagedMetadata) GetMetadata(key string) (interface{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.lookup(key)
+}
+
+// SetMetadata stages value with key, with the lifecycle set by options.
+func (m *StagedMetadata) SetMetadata(key string, value interface{}, options ...MetadataOption) {
+	m.mu.Lock()
+
+	if m.committed {
+		m.mu.Unlock()
+		m.instance.setMetadata(key, value, options)
+
+		return
+	}
+
+	defer m.mu.Unlock()
+
+	entry := m.instance.newMetadataEntry(options)
+	m.staged[key] = stagedValue{value: value, entry: &entry}
+}
+
+// DeleteMetadata stages the removal of the value stored with key.
+func (m *StagedMetadata) DeleteMetadata(key string) {
+	m.mu.Lock()
+
+	if m.committed {
+		m.mu.Unlock()
+		m.instance.deleteMetadata(key)
+
+		return
+	}
+
+	defer m.mu.Unlock()
+
+	m.staged[key] = stagedValue{deleted: true}
+}
+
+// UpdateMetadata stages the result of update, called with the value returned
+// by GetMetadata, and returns it. The value keeps its scope and expiration.
+func (m *StagedMetadata) UpdateMetadata(key string, update func(value interface{}, ok bool) interface{}) interface{} {
+	m.mu.Lock()
+
+	if m.committed {
+		m.mu.Unlock()
+
+		return m.instance.updateMetadata(key, update)
+	}
+
+	defer m.mu.Unlock()
+
+	value, ok := m.lookup(key)
+	value = update(value, ok)
+
+	staged := m.staged[key]
+	if !ok {
+		staged.entry = &metadataEntry{}
+	}
+	m.staged[key] = stagedValue{value: value, entry: staged.entry}
+
+	return value
+}
+
+// lookup returns the value staged with key or else stored in the metadata of
+// the instance, unless it expired. m.mu must be held.
+func (m *StagedMetadata) lookup(key string) (interface{}, bool) {
+	staged, ok := m.staged[key]
+	if !ok {
+		return m.instance.getMetadata(key)
+	}
+
+	if staged.deleted || staged.entry != nil && staged.entry.expired(m.instance.now()) {
+		return nil, false
+	}
+
+	if staged.entry == nil {
+		// An update of a value stored in the instance, which may have expired
+		// since.
+		if _, ok := m.instance.getMetadata(key); !ok {
+			return nil, false
+		}
+	}
+
+	return staged.value, true
+}
+
+// commit writes the staged values to the metadata of the instance. The
+// metadata hook is called once the view is unlocked, since it may go through
+// the view to access the metadata.
+func (m *StagedMetadata) commit() {
+	m.mu.Lock()
+
+	if m.committed {
+		m.mu.Unlock()
+
+		return
+	}
+
+	var changes []MetadataChange
+
+	m.instance.metadataMu.Lock()
+	for key, staged := range m.staged {
+		if !staged.deleted {
+			changes = append(changes, m.instance.storeMetadata(key, staged.value, staged.entry))
+		} else if change, ok := m.instance.removeMetadata(key, MetadataDeleted); ok {
+			changes = append(changes, change)
+		}
+	}
+	m.instance.metadataMu.Unlock()
+
+	m.staged = nil
+	m.committed = true
+	m.mu.Unlock()
+
+	m.instance.notifyMetadata(changes...)
+}
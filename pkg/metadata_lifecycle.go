@@ -0,0 +1,196 @@
+package pkg
+
+import "time"
+
+// MetadataOption configures the lifecycle of a metadata value.
+type MetadataOption func(*metadataEntry)
+
+// InState scopes the value to state: it is removed when the instance exits
+// state, either by a transition or by SetState.
+func InState(state string) MetadataOption {
+	return func(entry *metadataEntry) {
+		entry.state = state
+	}
+}
+
+// WithTTL makes the value expire ttl after it is stored, as measured by the
+// clock of the instance. Expired values are no longer returned and are removed
+// by Instance.ExpireMetadata.
+func WithTTL(ttl time.Duration) MetadataOption {
+	return func(entry *metadataEntry) {
+		entry.ttl = ttl
+	}
+}
+
+// WithMetadataClock sets the function used to read the current time for
+// metadata expirations. It defaults to time.Now.
+func WithMetadataClock(now func() time.Time) InstanceOption {
+	return func(instance *Instance) {
+		instance.now = now
+	}
+}
+
+// WithMetadataHook sets a function called after each change of the metadata
+// of the instance. It is called synchronously, without holding the metadata
+// lock, from the goroutine making the change.
+func WithMetadataHook(hook func(MetadataChange)) InstanceOption {
+	return func(instance *Instance) {
+		instance.metadataHook = hook
+	}
+}
+
+// MetadataChangeCause tells why a metadata value changed.
+type MetadataChangeCause uint8
+
+const (
+	// MetadataSet is a value stored or updated.
+	MetadataSet MetadataChangeCause = iota
+	// MetadataDeleted is a value deleted.
+	MetadataDeleted
+	// MetadataExpired is a value removed because its TTL elapsed.
+	MetadataExpired
+	// MetadataStateExited is a value removed because the instance exited the
+	// state it was scoped to.
+	MetadataStateExited
+)
+
+// MetadataChange describes a change of the metadata of an instance.
+type MetadataChange struct {
+	// Instance is the instance whose metadata changed.
+	Instance *Instance
+
+	// Key is the key of the value that changed.
+	Key string
+
+	// Old is the previous value, nil if there was none.
+	Old interface{}
+
+	// New is the new value, nil if the value was removed.
+	New interface{}
+
+	// Cause tells why the value changed.
+	Cause MetadataChangeCause
+}
+
+// metadataEntry is the lifecycle of a metadata value.
+type metadataEntry struct {
+	// state is the state the value is scoped to, if any.
+	state string
+	// ttl is the time to live given with WithTTL, used to compute expires.
+	ttl time.Duration
+	// expires is when the value expires, if ever.
+	expires time.Time
+}
+
+// expired returns true if the entry expired at now.
+func (e metadataEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// newMetadataEntry returns the lifecycle configured by options, starting now.
+func (f *Instance) newMetadataEntry(options []MetadataOption) metadataEntry {
+	var entry metadataEntry
+	for _, option := range options {
+		option(&entry)
+	}
+
+	if entry.ttl > 0 {
+		entry.expires = f.now().Add(entry.ttl)
+	}
+
+	return entry
+}
+
+// lookupMetadata returns the value stored with key unless it expired.
+// f.metadataMu must be held.
+func (f *Instance) lookupMetadata(key string) (interface{}, bool) {
+	value, ok := f.metadata[key]
+	if ok && f.metadataEntries[key].expired(f.now()) {
+		return nil, false
+	}
+
+	return value, ok
+}
+
+// storeMetadata stores value with key, replacing its lifecycle by entry unless
+// it is nil. f.metadataMu must be held.
+func (f *Instance) storeMetadata(key string, value interface{}, entry *metadataEntry) MetadataChange {
+	old, ok := f.lookupMetadata(key)
+	if !ok {
+		delete(f.metadataEntries, key)
+	}
+
+	f.metadata[key] = value
+	if entry != nil {
+		if *entry == (metadataEntry{}) {
+			delete(f.metadataEntries, key)
+		} else {
+			f.metadataEntries[key] = *entry
+		}
+	}
+
+	return MetadataChange{f, key, old, value, MetadataSet}
+}
+
+// removeMetadata removes the value stored with key. It returns false if there
+// was no value or it expired, which is then removed silently.
+// f.metadataMu must be held.
+func (f *Instance) removeMetadata(key string, cause MetadataChangeCause) (MetadataChange, bool) {
+	old, ok := f.lookupMetadata(key)
+
+	delete(f.metadata, key)
+	delete(f.metadataEntries, key)
+
+	return MetadataChange{f, key, old, nil, cause}, ok
+}
+
+// notifyMetadata calls the metadata hook with the changes.
+func (f *Instance) notifyMetadata(changes ...MetadataChange) {
+	if f.metadataHook == nil {
+		return
+	}
+
+	for _, change := range changes {
+		f.metadataHook(change)
+	}
+}
+
+// ExpireMetadata removes the metadata values whose TTL elapsed, notifying the
+// metadata hook.
+func (f *Instance) ExpireMetadata() {
+	f.metadataMu.Lock()
+
+	now := f.now()
+	var changes []MetadataChange
+	for key, entry := range f.metadataEntries {
+		if entry.expired(now) {
+			changes = append(changes, MetadataChange{f, key, f.metadata[key], nil, MetadataExpired})
+			delete(f.metadata, key)
+			delete(f.metadataEntries, key)
+		}
+	}
+
+	f.metadataMu.Unlock()
+
+	f.notifyMetadata(changes...)
+}
+
+// exitMetadataState removes the metadata values scoped to state.
+func (f *Instance) exitMetadataState(state string) {
+	f.metadataMu.Lock()
+
+	var changes []MetadataChange
+	for key, entry := range f.metadataEntries {
+		if entry.state != state {
+			continue
+		}
+
+		if change, ok := f.removeMetadata(key, MetadataStateExited); ok {
+			changes = append(changes, change)
+		}
+	}
+
+	f.metadataMu.Unlock()
+
+	f.notifyMetadata(changes...)
+}
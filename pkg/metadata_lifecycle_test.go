@@ -0,0 +1,200 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMetadataStateScope(t *testing.T) {
+	var changes []MetadataChange
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "offer", Sources: []string{"searching"}, Destination: "offering"},
+			{Name: "retry", Sources: []string{"offering"}, Destination: "offering"},
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+		},
+		map[string]Callback{
+			"enter_offering": func(e *Transition) {
+				e.Instance.SetMetadata("driver_offer", "alice", InState("offering"))
+			},
+			"leave_offering": func(e *Transition) {
+				if _, ok := e.Instance.GetMetadata("driver_offer"); !ok {
+					t.Error("expected the offer while leaving offering")
+				}
+			},
+			"enter_accepted": func(e *Transition) {
+				if _, ok := e.Instance.GetMetadata("driver_offer"); ok {
+					t.Error("expected no offer after leaving offering")
+				}
+			},
+		},
+	)
+
+	instance := machine.NewInstance("searching", WithMetadataHook(func(change MetadataChange) {
+		changes = append(changes, change)
+	}))
+	instance.SetMetadata("rider", "bob")
+
+	if err := instance.Transition(machine, "offer"); err != nil {
+		t.Fatal(err)
+	}
+	_ = instance.Transition(machine, "retry")
+	if _, ok := instance.GetMetadata("driver_offer"); !ok {
+		t.Error("expected the offer to survive a self transition")
+	}
+
+	if err := instance.Transition(machine, "accept"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := instance.GetMetadata("rider"); !ok {
+		t.Error("expected unscoped metadata to be kept")
+	}
+
+	wanted := []MetadataChange{
+		{instance, "rider", nil, "bob", MetadataSet},
+		{instance, "driver_offer", nil, "alice", MetadataSet},
+		{instance, "driver_offer", "alice", nil, MetadataStateExited},
+	}
+	if len(changes) != len(wanted) {
+		t.Fatalf("expected changes %v, got %v", wanted, changes)
+	}
+	for i := range wanted {
+		if changes[i] != wanted[i] {
+			t.Errorf("expected change %v, got %v", wanted[i], changes[i])
+		}
+	}
+
+	instance.SetMetadata("ride", 1, InState("accepted"))
+	instance.SetState("searching")
+	if _, ok := instance.GetMetadata("ride"); ok {
+		t.Error("expected SetState to exit the scope")
+	}
+}
+
+func TestMetadataTTL(t *testing.T) {
+	now := time.Unix(0, 0)
+	var changes []MetadataChange
+
+	machine := NewMachine(nil, map[string]Callback{}, WithMetadataKeys(NewKey[string]("", "token")))
+	instance := machine.NewInstance(
+		"start",
+		WithMetadataClock(func() time.Time { return now }),
+		WithMetadataHook(func(change MetadataChange) { changes = append(changes, change) }),
+	)
+
+	instance.SetMetadata("token", "secret", WithTTL(time.Minute))
+	instance.UpdateMetadata("token", func(value interface{}, _ bool) interface{} { return value.(string) + "!" })
+
+	now = now.Add(59 * time.Second)
+	if value, ok := instance.GetMetadata("token"); !ok || value != "secret!" {
+		t.Errorf("expected the token before it expires, got %v", value)
+	}
+
+	snapshot, err := instance.SnapshotMetadata(machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expires := snapshot["token"].Expires; expires == nil || !expires.Equal(time.Unix(60, 0)) {
+		t.Errorf("expected the snapshot to keep the expiration, got %v", expires)
+	}
+
+	now = now.Add(time.Second)
+	if _, ok := instance.GetMetadata("token"); ok {
+		t.Error("expected the token to expire")
+	}
+
+	snapshot, err = instance.SnapshotMetadata(machine)
+	if err != nil || len(snapshot) != 0 {
+		t.Errorf("expected expired metadata out of the snapshot, got %v, %v", snapshot, err)
+	}
+
+	instance.ExpireMetadata()
+	instance.ExpireMetadata()
+
+	if len(changes) != 3 || changes[2] != (MetadataChange{instance, "token", "secret!", nil, MetadataExpired}) {
+		t.Errorf("expected the expiration to be notified once, got %v", changes)
+	}
+
+	instance.SetMetadata("token", "other", WithTTL(time.Minute))
+	instance.SetMetadata("token", "other")
+	now = now.Add(time.Hour)
+	if _, ok := instance.GetMetadata("token"); !ok {
+		t.Error("expected SetMetadata to replace the expiration")
+	}
+}
+
+func TestStagedMetadataLifecycle(t *testing.T) {
+	now := time.Unix(0, 0)
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "offer", Sources: []string{"searching"}, Destination: "offering"},
+		},
+		map[string]Callback{
+			"before_offer": func(e *Transition) {
+				e.Metadata().SetMetadata("search", true, InState("searching"))
+				e.Metadata().SetMetadata("quote", 10, WithTTL(time.Minute))
+			},
+		},
+	)
+
+	instance := machine.NewInstance("searching", WithMetadataClock(func() time.Time { return now }))
+	if err := instance.Transition(machine, "offer"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := instance.GetMetadata("search"); ok {
+		t.Error("expected staged metadata scoped to the source state to be removed")
+	}
+	if _, ok := instance.GetMetadata("quote"); !ok {
+		t.Error("expected the staged quote to be committed")
+	}
+
+	now = now.Add(time.Minute)
+	if _, ok := instance.GetMetadata("quote"); ok {
+		t.Error("expected the staged quote to keep its expiration")
+	}
+}
+
+func TestMetadataHookDuringTransition(t *testing.T) {
+	var instance *Instance
+	var seen []interface{}
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "offer", Sources: []string{"searching"}, Destination: "offering"},
+		},
+		map[string]Callback{
+			"before_offer": func(e *Transition) {
+				e.Instance.SetMetadata("quote", 10)
+			},
+			"enter_offering": func(e *Transition) {
+				e.Instance.SetMetadata("driver", "alice")
+			},
+		},
+	)
+
+	instance = machine.NewInstance("searching", WithMetadataHook(func(change MetadataChange) {
+		value, _ := instance.GetMetadata(change.Key)
+		seen = append(seen, value)
+
+		if change.Key == "quote" {
+			instance.SetMetadata("quoted", true)
+		}
+	}))
+
+	if err := instance.Transition(machine, "offer"); err != nil {
+		t.Fatal(err)
+	}
+
+	wanted := []interface{}{10, true, "alice"}
+	if len(seen) != len(wanted) {
+		t.Fatalf("expected the hook to see %v, got %v", wanted, seen)
+	}
+	for i := range wanted {
+		if seen[i] != wanted[i] {
+			t.Errorf("expected the hook to see %v, got %v", wanted[i], seen[i])
+		}
+	}
+}
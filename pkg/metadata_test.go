@@ -0,0 +1,266 @@
+package pkg
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+func TestKey(t *testing.T) {
+	instance := NewMachine(nil, map[string]Callback{}).NewInstance("start")
+	message := NewKey[string]("", "message")
+	count := NewKey[int]("pubsub", "message")
+
+	message.Set(instance, "hii")
+	count.Set(instance, 2)
+
+	if value, ok := message.Get(instance); !ok || value != "hii" {
+		t.Errorf("expected message hii, got %q", value)
+	}
+	if value, ok := instance.GetMetadata("message"); !ok || value != "hii" {
+		t.Errorf("expected keys in the empty namespace to share the metadata, got %v", value)
+	}
+	if value, ok := count.Get(instance); !ok || value != 2 {
+		t.Errorf("expected namespaced count 2, got %d", value)
+	}
+
+	instance.SetMetadata("message", 3)
+	if value, ok := message.Get(instance); ok {
+		t.Errorf("expected no message for a value of another type, got %q", value)
+	}
+
+	if value := count.Update(instance, func(value int, ok bool) int { return value + 1 }); value != 3 {
+		t.Errorf("expected updated count 3, got %d", value)
+	}
+
+	count.Delete(instance)
+	if _, ok := count.Get(instance); ok {
+		t.Error("expected the count to be deleted")
+	}
+	if value := count.Update(instance, func(value int, ok bool) int {
+		if ok {
+			t.Error("expected no count to update")
+		}
+
+		return 10
+	}); value != 10 {
+		t.Errorf("expected count 10, got %d", value)
+	}
+}
+
+func TestKeyUpdateInterface(t *testing.T) {
+	instance := NewMachine(nil, map[string]Callback{}).NewInstance("start")
+	lastErr := NewKey[error]("", "error")
+
+	if err := lastErr.Update(instance, func(error, bool) error { return nil }); err != nil {
+		t.Errorf("expected a nil error, got %v", err)
+	}
+	if err, ok := lastErr.Get(instance); ok || err != nil {
+		t.Errorf("expected a nil error not to be a stored error, got %v", err)
+	}
+}
+
+type ride struct {
+	Driver string
+	Fare   float64
+}
+
+func TestMetadataSnapshot(t *testing.T) {
+	rideKey := NewKey[ride]("rides", "ride")
+	countKey := NewKey[int]("rides", "count").WithCodec(
+		func(value int) ([]byte, error) { return []byte(strconv.Quote(strconv.Itoa(value))), nil },
+		func(data []byte) (int, error) {
+			s, err := strconv.Unquote(string(data))
+			if err != nil {
+				return 0, err
+			}
+
+			return strconv.Atoi(s)
+		},
+	)
+
+	machine := NewMachine(nil, map[string]Callback{}, WithMetadataKeys(rideKey, countKey))
+	instance := machine.NewInstance("start")
+	rideKey.Set(instance, ride{"alice", 12.5})
+	countKey.Set(instance, 4)
+
+	snapshot, err := instance.SnapshotMetadata(machine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(snapshot["rides/count"].Data) != `"4"` || string(snapshot["rides/ride"].Data) != `{"Driver":"alice","Fare":12.5}` {
+		t.Errorf("unexpected snapshot %s", snapshot)
+	}
+
+	restored := machine.NewInstance("start")
+	restored.SetMetadata("stale", true)
+	if err := restored.RestoreMetadata(machine, snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, ok := rideKey.Get(restored); !ok || value != (ride{"alice", 12.5}) {
+		t.Errorf("expected the ride to be restored typed, got %v", value)
+	}
+	if value, ok := countKey.Get(restored); !ok || value != 4 {
+		t.Errorf("expected the count to be restored typed, got %v", value)
+	}
+	if _, ok := restored.GetMetadata("stale"); ok {
+		t.Error("expected the metadata to be replaced")
+	}
+}
+
+func TestMetadataSnapshotErrors(t *testing.T) {
+	countKey := NewKey[int]("", "count")
+	machine := NewMachine(nil, map[string]Callback{}, WithMetadataKeys(countKey))
+	instance := machine.NewInstance("start")
+
+	instance.SetMetadata("message", "hii")
+	if _, err := instance.SnapshotMetadata(machine); !errors.As(err, new(UnregisteredMetadataError)) {
+		t.Errorf("expected UnregisteredMetadataError, got %v", err)
+	}
+
+	instance.DeleteMetadata("message")
+	instance.SetMetadata("count", "one")
+	if _, err := instance.SnapshotMetadata(machine); !errors.As(err, new(MetadataTypeError)) {
+		t.Errorf("expected MetadataTypeError, got %v", err)
+	}
+
+	countKey.Set(instance, 1)
+	err := instance.RestoreMetadata(machine, MetadataSnapshot{"count": {Data: []byte(`"one"`)}})
+	if !errors.As(err, new(MetadataSerializationError)) {
+		t.Errorf("expected MetadataSerializationError, got %v", err)
+	}
+	if value, _ := countKey.Get(instance); value != 1 {
+		t.Errorf("expected the metadata to be untouched by a failed restore, got %d", value)
+	}
+
+	err = instance.RestoreMetadata(machine, MetadataSnapshot{"message": {Data: []byte(`"hii"`)}})
+	if !errors.As(err, new(UnregisteredMetadataError)) {
+		t.Errorf("expected UnregisteredMetadataError, got %v", err)
+	}
+}
+
+func TestStagedMetadata(t *testing.T) {
+	count := NewKey[int]("", "count")
+	cancel := false
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "stay", Sources: []string{"closed"}, Destination: "closed"},
+		},
+		map[string]Callback{
+			"before_transition": func(e *Transition) {
+				count.Update(e.Metadata(), func(value int, _ bool) int { return value + 1 })
+				e.Instance.DeleteMetadata("deleted")
+				e.Instance.SetMetadata("plain", e.Name)
+
+				staged, _ := count.Get(e.Metadata())
+				if value, _ := e.Instance.getMetadata("count"); value != nil && value.(int) != staged-1 {
+					t.Errorf("expected the staged writes not to be stored yet, got %d", value)
+				}
+			},
+			"leave_closed": func(e *Transition) {
+				staged, _ := count.Get(e.Metadata())
+				if value, _ := count.Get(e.Instance); value != staged {
+					t.Errorf("expected the instance to see staged writes, got %d", value)
+				}
+				if _, ok := e.Metadata().GetMetadata("deleted"); ok {
+					t.Error("expected the transition to see staged deletions")
+				}
+				if value, _ := e.Metadata().GetMetadata("plain"); value != e.Name {
+					t.Error("expected the transition to see the writes made on the instance")
+				}
+
+				if cancel {
+					e.Cancel()
+				}
+			},
+			"enter_open": func(e *Transition) {
+				e.Metadata().SetMetadata("entered", true)
+			},
+		},
+	)
+
+	instance := machine.NewInstance("closed")
+	instance.SetMetadata("deleted", true)
+
+	cancel = true
+	if err := instance.Transition(machine, "open"); !errors.As(err, new(CanceledError)) {
+		t.Fatalf("expected CanceledError, got %v", err)
+	}
+	if _, ok := count.Get(instance); ok {
+		t.Error("expected the writes of a canceled transition to be discarded")
+	}
+	if _, ok := instance.GetMetadata("deleted"); !ok {
+		t.Error("expected the deletions of a canceled transition to be discarded")
+	}
+	if _, ok := instance.GetMetadata("plain"); ok {
+		t.Error("expected the writes made on the instance by a canceled transition to be discarded")
+	}
+
+	cancel = false
+	if err := instance.Transition(machine, "stay"); !errors.As(err, new(NoTransitionError)) {
+		t.Fatalf("expected NoTransitionError, got %v", err)
+	}
+	if value, _ := count.Get(instance); value != 1 {
+		t.Errorf("expected the writes of a self transition to be committed, got %d", value)
+	}
+
+	if err := instance.Transition(machine, "open"); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := count.Get(instance); value != 2 {
+		t.Errorf("expected the writes of the transition to be committed, got %d", value)
+	}
+	if _, ok := instance.GetMetadata("deleted"); ok {
+		t.Error("expected the deletions of the transition to be committed")
+	}
+	if _, ok := instance.GetMetadata("entered"); !ok {
+		t.Error("expected the writes after the commit to go through")
+	}
+	if value, _ := instance.GetMetadata("plain"); value != "open" {
+		t.Errorf("expected the writes made on the instance to be committed, got %v", value)
+	}
+}
+
+func TestHandBuiltTransitionMetadata(t *testing.T) {
+	instance := NewMachine(nil, map[string]Callback{}).NewInstance("start")
+	e := &Transition{Instance: instance, Name: "open"}
+
+	e.Metadata().SetMetadata("opened", true)
+	if _, ok := instance.GetMetadata("opened"); !ok {
+		t.Error("expected the view of a hand-built transition to write to its instance")
+	}
+}
+
+func TestStagedMetadataAsync(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{
+			"leave_closed": func(e *Transition) {
+				e.Metadata().SetMetadata("opened", true)
+				e.Instance.SetMetadata("x", 1)
+				e.Async()
+			},
+		},
+	)
+
+	instance := machine.NewInstance("closed")
+	if err := instance.Transition(machine, "open"); !errors.As(err, new(AsyncError)) {
+		t.Fatalf("expected AsyncError, got %v", err)
+	}
+	if _, ok := instance.GetMetadata("opened"); !ok {
+		t.Error("expected the writes to be committed once the transition is asynchronous")
+	}
+
+	instance.SetMetadata("x", 2)
+	if err := instance.CompleteTransition(machine); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := instance.GetMetadata("x"); value != 2 {
+		t.Errorf("expected the writes made while pending to be kept, got %v", value)
+	}
+}
@@ -0,0 +1,372 @@
+// Package metrics provides an fsm.Observer collecting transition metrics and
+// exposing them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// DefaultBuckets are the default histogram buckets in seconds, the same as the
+// Prometheus client's default buckets.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// contentType is the content type of the Prometheus text exposition format.
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Option configures optional behaviour of Metrics.
+type Option func(*Metrics)
+
+// WithNamespace sets the prefix of the metric names, "fsm" by default.
+func WithNamespace(namespace string) Option {
+	return func(m *Metrics) {
+		m.namespace = namespace
+	}
+}
+
+// WithBuckets sets the upper bounds in seconds of the duration histograms.
+func WithBuckets(buckets []float64) Option {
+	return func(m *Metrics) {
+		m.buckets = append([]float64(nil), buckets...)
+		sort.Float64s(m.buckets)
+	}
+}
+
+// WithRegistry adds the instances of registry to the number of instances in
+// each state, which is counted from the registries whenever the metrics are
+// written.
+func WithRegistry(registry *fsm.Registry) Option {
+	return func(m *Metrics) {
+		m.registries = append(m.registries, registry)
+	}
+}
+
+// Metrics is an fsm.Observer counting transitions, timing transitions and
+// callbacks and tracking the number of instances in each state of the
+// registries given with WithRegistry. It serves the collected metrics over
+// HTTP in the Prometheus text exposition format.
+//
+// It has to be created with New and added to machines with fsm.WithObserver or
+// Machine.AddObserver.
+type Metrics struct {
+	fsm.NopObserver
+
+	namespace  string
+	buckets    []float64
+	registries []*fsm.Registry
+
+	// mu guards the collected metrics below.
+	mu                  sync.Mutex
+	transitions         map[transitionLabels]uint64
+	transitionDurations map[durationLabels]*histogram
+	callbackDurations   map[callbackLabels]*histogram
+}
+
+type transitionLabels struct {
+	machine, event, src, dst, outcome string
+}
+
+type durationLabels struct {
+	machine, event, outcome string
+}
+
+type callbackLabels struct {
+	machine, hook, callback string
+}
+
+type stateLabels struct {
+	machine, state string
+}
+
+// histogram is a cumulative histogram of durations in seconds.
+type histogram struct {
+	// counts holds a counter per bucket, each counting the observations lower
+	// than or equal to its upper bound.
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// New returns empty metrics.
+func New(options ...Option) *Metrics {
+	m := &Metrics{
+		namespace:           "fsm",
+		buckets:             DefaultBuckets,
+		transitions:         make(map[transitionLabels]uint64),
+		transitionDurations: make(map[durationLabels]*histogram),
+		callbackDurations:   make(map[callbackLabels]*histogram),
+	}
+
+	for _, option := range options {
+		option(m)
+	}
+
+	return m
+}
+
+// CallbackFinished implements fsm.Observer.
+func (m *Metrics) CallbackFinished(machine *fsm.Machine, _ *fsm.Transition, hook fsm.Hook, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels := callbackLabels{machine.Name(), string(hook.Type), hook.String()}
+
+	h, ok := m.callbackDurations[labels]
+	if !ok {
+		h = m.newHistogram()
+		m.callbackDurations[labels] = h
+	}
+
+	h.observe(m.buckets, duration)
+}
+
+// TransitionFinished implements fsm.Observer.
+func (m *Metrics) TransitionFinished(machine *fsm.Machine, t *fsm.Transition, err error, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	outcome := fsm.Outcome(err)
+
+	m.transitions[transitionLabels{machine.Name(), t.Name, t.Src, t.Dst, outcome}]++
+
+	labels := durationLabels{machine.Name(), t.Name, outcome}
+
+	h, ok := m.transitionDurations[labels]
+	if !ok {
+		h = m.newHistogram()
+		m.transitionDurations[labels] = h
+	}
+
+	h.observe(m.buckets, duration)
+}
+
+// ServeHTTP writes the collected metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the collected metrics in the Prometheus text exposition
+// format to w.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	// count before locking, since transitions hold the state of their
+	// instance while notifying the metrics
+	instances := m.countInstances()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	writer := &countingWriter{w: bufio.NewWriter(w)}
+
+	m.writeTransitions(writer)
+	m.writeTransitionDurations(writer)
+	m.writeCallbackDurations(writer)
+	m.writeInstances(writer, instances)
+
+	if writer.err == nil {
+		writer.err = writer.w.Flush()
+	}
+
+	return writer.n, writer.err
+}
+
+func (m *Metrics) writeTransitions(w *countingWriter) {
+	name := m.namespace + "_transitions_total"
+	writeHeader(w, name, "counter", "Number of processed events by source, destination and outcome.")
+
+	keys := make([]transitionLabels, 0, len(m.transitions))
+	for key := range m.transitions {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return lessStrings(
+			[]string{keys[i].machine, keys[i].event, keys[i].src, keys[i].dst, keys[i].outcome},
+			[]string{keys[j].machine, keys[j].event, keys[j].src, keys[j].dst, keys[j].outcome},
+		)
+	})
+
+	for _, key := range keys {
+		labels := formatLabels("machine", key.machine, "event", key.event, "src", key.src, "dst", key.dst, "outcome", key.outcome)
+		w.printf("%s%s %d\n", name, labels, m.transitions[key])
+	}
+}
+
+func (m *Metrics) writeTransitionDurations(w *countingWriter) {
+	name := m.namespace + "_transition_duration_seconds"
+	writeHeader(w, name, "histogram", "Time spent processing an event, including its callbacks.")
+
+	keys := make([]durationLabels, 0, len(m.transitionDurations))
+	for key := range m.transitionDurations {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return lessStrings(
+			[]string{keys[i].machine, keys[i].event, keys[i].outcome},
+			[]string{keys[j].machine, keys[j].event, keys[j].outcome},
+		)
+	})
+
+	for _, key := range keys {
+		m.writeHistogram(w, name, m.transitionDurations[key], "machine", key.machine, "event", key.event, "outcome", key.outcome)
+	}
+}
+
+func (m *Metrics) writeCallbackDurations(w *countingWriter) {
+	name := m.namespace + "_callback_duration_seconds"
+	writeHeader(w, name, "histogram", "Time spent running a callback.")
+
+	keys := make([]callbackLabels, 0, len(m.callbackDurations))
+	for key := range m.callbackDurations {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return lessStrings(
+			[]string{keys[i].machine, keys[i].hook, keys[i].callback},
+			[]string{keys[j].machine, keys[j].hook, keys[j].callback},
+		)
+	})
+
+	for _, key := range keys {
+		m.writeHistogram(w, name, m.callbackDurations[key], "machine", key.machine, "hook", key.hook, "callback", key.callback)
+	}
+}
+
+// countInstances returns the number of instances of the registries in each
+// state, including the states of their machines without instances.
+func (m *Metrics) countInstances() map[stateLabels]int {
+	instances := make(map[stateLabels]int)
+
+	for _, registry := range m.registries {
+		machine := registry.Machine()
+
+		for key, dst := range machine.Transitions() {
+			instances[stateLabels{machine.Name(), key.Src}] += 0
+			instances[stateLabels{machine.Name(), dst}] += 0
+		}
+
+		for state, count := range registry.CountByState() {
+			instances[stateLabels{machine.Name(), state}] += count
+		}
+	}
+
+	return instances
+}
+
+func (m *Metrics) writeInstances(w *countingWriter, instances map[stateLabels]int) {
+	name := m.namespace + "_instances"
+	writeHeader(w, name, "gauge", "Number of instances in each state.")
+
+	keys := make([]stateLabels, 0, len(instances))
+	for key := range instances {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return lessStrings([]string{keys[i].machine, keys[i].state}, []string{keys[j].machine, keys[j].state})
+	})
+
+	for _, key := range keys {
+		w.printf("%s%s %d\n", name, formatLabels("machine", key.machine, "state", key.state), instances[key])
+	}
+}
+
+func (m *Metrics) writeHistogram(w *countingWriter, name string, h *histogram, labels ...string) {
+	for i, bound := range m.buckets {
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		w.printf("%s_bucket%s %d\n", name, formatLabels(append(labels, "le", le)...), h.counts[i])
+	}
+
+	w.printf("%s_bucket%s %d\n", name, formatLabels(append(labels, "le", "+Inf")...), h.count)
+	w.printf("%s_sum%s %s\n", name, formatLabels(labels...), strconv.FormatFloat(h.sum, 'g', -1, 64))
+	w.printf("%s_count%s %d\n", name, formatLabels(labels...), h.count)
+}
+
+func (m *Metrics) newHistogram() *histogram {
+	return &histogram{counts: make([]uint64, len(m.buckets))}
+}
+
+// observe adds a duration to the histogram.
+func (h *histogram) observe(buckets []float64, duration time.Duration) {
+	seconds := duration.Seconds()
+
+	for i, bound := range buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+
+	h.count++
+	h.sum += seconds
+}
+
+func writeHeader(w *countingWriter, name, metricType, help string) {
+	w.printf("# HELP %s %s\n", name, help)
+	w.printf("# TYPE %s %s\n", name, metricType)
+}
+
+// labelEscaper escapes label values as required by the exposition format.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats alternating label names and values.
+func formatLabels(pairs ...string) string {
+	var builder strings.Builder
+
+	builder.WriteString("{")
+
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			builder.WriteString(",")
+		}
+
+		builder.WriteString(pairs[i])
+		builder.WriteString(`="`)
+		builder.WriteString(labelEscaper.Replace(pairs[i+1]))
+		builder.WriteString(`"`)
+	}
+
+	builder.WriteString("}")
+
+	return builder.String()
+}
+
+// lessStrings compares two slices of the same length lexicographically.
+func lessStrings(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return false
+}
+
+// countingWriter keeps the first error and the number of bytes written.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (w *countingWriter) printf(format string, args ...interface{}) {
+	if w.err != nil {
+		return
+	}
+
+	n, err := fmt.Fprintf(w.w, format, args...)
+	w.n += int64(n)
+	w.err = err
+}
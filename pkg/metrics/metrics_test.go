@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	machine := fsm.NewMachine(
+		[]fsm.TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]fsm.Callback{
+			"before_close": func(t *fsm.Transition) {
+				t.Cancel()
+			},
+		},
+		fsm.WithName("door"),
+	)
+
+	registry := fsm.NewRegistry(machine, "closed")
+	metrics := New(WithBuckets([]float64{1, 0.5}), WithRegistry(registry))
+	machine.AddObserver(metrics)
+
+	_ = registry.Dispatch("a", "open")
+	_ = registry.Dispatch("a", "close")
+	_ = registry.Dispatch("a", "open")
+	_ = registry.Dispatch("a", "lock")
+
+	// the gauge follows SetState and removals, and ignores instances outside
+	// of the registry
+	instance, release := registry.GetOrCreate("b")
+	instance.SetState("open")
+	release()
+	_ = registry.Dispatch("c", "open")
+	registry.Remove("c")
+	machine.NewInstance("open")
+
+	server := httptest.NewServer(metrics)
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer response.Body.Close()
+
+	if contentType := response.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %s", contentType)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	expected := []string{
+		"# TYPE fsm_transitions_total counter",
+		`fsm_transitions_total{machine="door",event="close",src="open",dst="closed",outcome="canceled"} 1`,
+		`fsm_transitions_total{machine="door",event="lock",src="open",dst="",outcome="unknown_event"} 1`,
+		`fsm_transitions_total{machine="door",event="open",src="closed",dst="open",outcome="ok"} 2`,
+		`fsm_transitions_total{machine="door",event="open",src="open",dst="",outcome="invalid_event"} 1`,
+		"# TYPE fsm_transition_duration_seconds histogram",
+		`fsm_transition_duration_seconds_bucket{machine="door",event="open",outcome="ok",le="0.5"} 2`,
+		`fsm_transition_duration_seconds_bucket{machine="door",event="open",outcome="ok",le="+Inf"} 2`,
+		`fsm_transition_duration_seconds_count{machine="door",event="open",outcome="ok"} 2`,
+		`fsm_callback_duration_seconds_count{machine="door",hook="before",callback="before_close"} 1`,
+		"# TYPE fsm_instances gauge",
+		`fsm_instances{machine="door",state="closed"} 0`,
+		`fsm_instances{machine="door",state="open"} 2`,
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(string(body), line+"\n") {
+			t.Errorf("expected output to contain %q, got\n%s", line, body)
+		}
+	}
+}
+
+func TestHistogramBuckets(t *testing.T) {
+	h := &histogram{counts: make([]uint64, 2)}
+	buckets := []float64{0.1, 1}
+
+	h.observe(buckets, 50*time.Millisecond)
+	h.observe(buckets, 500*time.Millisecond)
+	h.observe(buckets, 5*time.Second)
+
+	if h.counts[0] != 1 || h.counts[1] != 2 || h.count != 3 {
+		t.Errorf("unexpected bucket counts %v of %d", h.counts, h.count)
+	}
+}
+
+func TestFormatLabelsEscapes(t *testing.T) {
+	got := formatLabels("state", "on \"hold\"\\\n")
+	if got != `{state="on \"hold\"\\\n"}` {
+		t.Errorf("unexpected labels %s", got)
+	}
+}
@@ -0,0 +1,169 @@
+package pkg
+
+import (
+	"errors"
+	"time"
+)
+
+// Outcomes of a transition as reported by Outcome.
+const (
+	OutcomeOK             = "ok"
+	OutcomeNoTransition   = "no_transition"
+	OutcomeCanceled       = "canceled"
+	OutcomeAsync          = "async"
+	OutcomeInTransition   = "in_transition"
+	OutcomeInvalidEvent   = "invalid_event"
+	OutcomeUnknownEvent   = "unknown_event"
+	OutcomeInvalidPayload = "invalid_payload"
+	OutcomeError          = "error"
+)
+
+// Outcome classifies the error returned by Instance.Transition into one of a
+// small set of values suitable for metric labels and log fields.
+func Outcome(err error) string {
+	switch {
+	case err == nil:
+		return OutcomeOK
+	case errors.As(err, new(NoTransitionError)):
+		return OutcomeNoTransition
+	case errors.As(err, new(CanceledError)):
+		return OutcomeCanceled
+	case errors.As(err, new(AsyncError)):
+		return OutcomeAsync
+	case errors.As(err, new(InTransitionError)):
+		return OutcomeInTransition
+	case errors.As(err, new(InvalidEventError)):
+		return OutcomeInvalidEvent
+	case errors.As(err, new(UnknownEventError)):
+		return OutcomeUnknownEvent
+	case errors.As(err, new(InvalidPayloadError)):
+		return OutcomeInvalidPayload
+	default:
+		return OutcomeError
+	}
+}
+
+// Observer is notified by the transition pipeline of the machines it is added
+// to, either with WithObserver or Machine.AddObserver.
+//
+// Observers are called synchronously from the goroutine running the transition
+// and must not call Instance.Transition themselves. Implementations can embed
+// NopObserver and only override the methods they need.
+type Observer interface {
+	// InstanceCreated is called when the machine creates an instance.
+	InstanceCreated(machine *Machine, instance *Instance)
+
+	// InstanceRemoved is called when an instance is discarded by its owner,
+	// like a Registry evicting it.
+	InstanceRemoved(machine *Machine, instance *Instance)
+
+	// TransitionStarted is called before an event is processed. Dst is empty
+	// if the event cannot occur in the current state. It is called again with
+	// the same transition, for which IsAsync returns true, when an
+	// asynchronous transition is completed with Instance.CompleteTransition.
+	TransitionStarted(machine *Machine, t *Transition)
+
+	// CallbackStarted is called before a callback runs.
+	CallbackStarted(machine *Machine, t *Transition, hook Hook)
+
+	// CallbackFinished is called after a callback returns.
+	CallbackFinished(machine *Machine, t *Transition, hook Hook, duration time.Duration)
+
+	// TransitionFinished is called once an event is processed, with the error
+	// it resulted in and the time it took.
+	TransitionFinished(machine *Machine, t *Transition, err error, duration time.Duration)
+}
+
+// NopObserver is an Observer that does nothing, to be embedded by observers
+// interested in a subset of the notifications.
+type NopObserver struct{}
+
+// InstanceCreated implements Observer.
+func (NopObserver) InstanceCreated(*Machine, *Instance) {}
+
+// InstanceRemoved implements Observer.
+func (NopObserver) InstanceRemoved(*Machine, *Instance) {}
+
+// TransitionStarted implements Observer.
+func (NopObserver) TransitionStarted(*Machine, *Transition) {}
+
+// CallbackStarted implements Observer.
+func (NopObserver) CallbackStarted(*Machine, *Transition, Hook) {}
+
+// CallbackFinished implements Observer.
+func (NopObserver) CallbackFinished(*Machine, *Transition, Hook, time.Duration) {}
+
+// TransitionFinished implements Observer.
+func (NopObserver) TransitionFinished(*Machine, *Transition, error, time.Duration) {}
+
+// WithObserver adds an observer to the machine's transition pipeline.
+func WithObserver(observer Observer) MachineOption {
+	return func(machine *Machine) {
+		machine.observers = append(machine.observers, observer)
+	}
+}
+
+// AddObserver adds an observer to the machine's transition pipeline. It is
+// safe to call while instances of the machine are in use.
+func (machine *Machine) AddObserver(observer Observer) {
+	machine.observersMu.Lock()
+	defer machine.observersMu.Unlock()
+
+	observers := make([]Observer, len(machine.observers), len(machine.observers)+1)
+	copy(observers, machine.observers)
+	machine.observers = append(observers, observer)
+}
+
+// getObservers returns the current observers of the machine.
+func (machine *Machine) getObservers() []Observer {
+	machine.observersMu.RLock()
+	defer machine.observersMu.RUnlock()
+
+	return machine.observers
+}
+
+func (machine *Machine) instanceCreated(instance *Instance) {
+	for _, observer := range machine.getObservers() {
+		observer.InstanceCreated(machine, instance)
+	}
+}
+
+func (machine *Machine) instanceRemoved(instance *Instance) {
+	for _, observer := range machine.getObservers() {
+		observer.InstanceRemoved(machine, instance)
+	}
+}
+
+func (machine *Machine) transitionStarted(t *Transition) {
+	for _, observer := range machine.getObservers() {
+		observer.TransitionStarted(machine, t)
+	}
+}
+
+func (machine *Machine) transitionFinished(t *Transition, err error, duration time.Duration) {
+	for _, observer := range machine.getObservers() {
+		observer.TransitionFinished(machine, t, err, duration)
+	}
+}
+
+// runCallback runs fn for the given hook, notifying the observers around it.
+func (machine *Machine) runCallback(fn Callback, t *Transition, hook Hook) {
+	observers := machine.getObservers()
+	if len(observers) == 0 {
+		fn(t)
+
+		return
+	}
+
+	for _, observer := range observers {
+		observer.CallbackStarted(machine, t, hook)
+	}
+
+	start := time.Now()
+	fn(t)
+	duration := time.Since(start)
+
+	for _, observer := range observers {
+		observer.CallbackFinished(machine, t, hook, duration)
+	}
+}
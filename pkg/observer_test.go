@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// recordingObserver records the notifications it receives.
+type recordingObserver struct {
+	NopObserver
+
+	events []string
+}
+
+func (o *recordingObserver) InstanceCreated(_ *Machine, instance *Instance) {
+	o.events = append(o.events, "created:"+instance.Current())
+}
+
+func (o *recordingObserver) TransitionStarted(_ *Machine, t *Transition) {
+	o.events = append(o.events, "started:"+t.Name+":"+t.Src+">"+t.Dst)
+}
+
+func (o *recordingObserver) CallbackStarted(_ *Machine, _ *Transition, hook Hook) {
+	o.events = append(o.events, "callback:"+hook.String())
+}
+
+func (o *recordingObserver) TransitionFinished(_ *Machine, t *Transition, err error, _ time.Duration) {
+	o.events = append(o.events, "finished:"+t.Name+":"+Outcome(err))
+}
+
+func TestObserverNotifications(t *testing.T) {
+	observer := &recordingObserver{}
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+		},
+		map[string]Callback{
+			"before_open": func(t *Transition) {},
+			"leave_state": func(t *Transition) {},
+			"open":        func(t *Transition) {},
+		},
+	)
+	machine.AddObserver(observer)
+
+	instance := machine.NewInstance("closed")
+	_ = instance.Transition(machine, "open")
+	_ = instance.Transition(machine, "open")
+
+	expected := []string{
+		"created:closed",
+		"started:open:closed>open",
+		"callback:before_open",
+		"callback:leave_state",
+		"callback:enter_open",
+		"finished:open:ok",
+		"started:open:open>",
+		"finished:open:invalid_event",
+	}
+
+	if len(observer.events) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, observer.events)
+	}
+
+	for i := range expected {
+		if observer.events[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, observer.events)
+		}
+	}
+}
+
+func TestOutcome(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{nil, OutcomeOK},
+		{NoTransitionError{}, OutcomeNoTransition},
+		{CanceledError{}, OutcomeCanceled},
+		{AsyncError{}, OutcomeAsync},
+		{InTransitionError{}, OutcomeInTransition},
+		{InvalidEventError{}, OutcomeInvalidEvent},
+		{UnknownEventError{}, OutcomeUnknownEvent},
+		{InvalidPayloadError{Err: errors.New("no payload")}, OutcomeInvalidPayload},
+		{RaisedEventError{Err: CanceledError{}}, OutcomeCanceled},
+		{errors.New("boom"), OutcomeError},
+	}
+
+	for _, test := range tests {
+		if got := Outcome(test.err); got != test.expected {
+			t.Errorf("expected outcome %s for %v, got %s", test.expected, test.err, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// PayloadSchema is the contract of the arguments of an event, validated by
+// Instance.Transition before running the before_ callbacks.
+type PayloadSchema interface {
+	// Event returns the name of the event the schema applies to.
+	Event() string
+	// ValidatePayload returns the payload carried by the arguments of the
+	// event or the reason they don't match the schema.
+	ValidatePayload(args []interface{}) (interface{}, error)
+}
+
+// WithPayloads registers the payload schemas of the machine's events. Events
+// with a schema fail with InvalidPayloadError when given arguments that don't
+// match it.
+func WithPayloads(schemas ...PayloadSchema) MachineOption {
+	return func(machine *Machine) {
+		if machine.payloads == nil {
+			machine.payloads = make(map[string]PayloadSchema, len(schemas))
+		}
+
+		for _, schema := range schemas {
+			machine.payloads[schema.Event()] = schema
+		}
+	}
+}
+
+// Payload is the schema of an event taking a single argument of type T,
+// sparing callbacks the type assertions of Transition.Args.
+type Payload[T any] struct {
+	event    string
+	validate func(T) error
+}
+
+// NewPayload returns the schema of event, taking a single argument of type T.
+func NewPayload[T any](event string) Payload[T] {
+	return Payload[T]{event: event}
+}
+
+// WithValidation returns a copy of the schema also rejecting the payloads for
+// which validate returns an error.
+func (p Payload[T]) WithValidation(validate func(T) error) Payload[T] {
+	p.validate = validate
+
+	return p
+}
+
+// Event returns the name of the event the schema applies to.
+func (p Payload[T]) Event() string {
+	return p.event
+}
+
+// ValidatePayload implements PayloadSchema.
+func (p Payload[T]) ValidatePayload(args []interface{}) (interface{}, error) {
+	payloadType := reflect.TypeOf((*T)(nil)).Elem()
+
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument of type %s, got %d", payloadType, len(args))
+	}
+
+	payload, ok := args[0].(T)
+	if !ok {
+		return nil, fmt.Errorf("expected an argument of type %s, got %T", payloadType, args[0])
+	}
+
+	if p.validate != nil {
+		if err := p.validate(payload); err != nil {
+			return nil, err
+		}
+	}
+
+	return payload, nil
+}
+
+// Get returns the payload of the transition. It returns false if the
+// transition is not of the schema's event or carries no valid payload.
+func (p Payload[T]) Get(t *Transition) (T, bool) {
+	if t.Name != p.event {
+		return *new(T), false
+	}
+
+	payload, ok := t.payload.(T)
+
+	return payload, ok
+}
+
+// validatePayload sets the payload of the transition from its arguments if
+// its event has a schema.
+func (machine *Machine) validatePayload(t *Transition) error {
+	schema, ok := machine.payloads[t.Name]
+	if !ok {
+		return nil
+	}
+
+	payload, err := schema.ValidatePayload(t.Args)
+	if err != nil {
+		return InvalidPayloadError{t.Name, err}
+	}
+
+	t.payload = payload
+
+	return nil
+}
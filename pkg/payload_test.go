@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+type payment struct {
+	Amount int
+}
+
+func TestPayload(t *testing.T) {
+	pay := NewPayload[payment]("pay").WithValidation(func(p payment) error {
+		if p.Amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+
+		return nil
+	})
+
+	var paid []int
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "pay", Sources: []string{"unpaid"}, Destination: "paid"},
+			{Name: "refund", Sources: []string{"paid"}, Destination: "unpaid"},
+		},
+		map[string]Callback{
+			"before_transition": func(e *Transition) {
+				if e.Name != "pay" {
+					return
+				}
+
+				p, ok := pay.Get(e)
+				if !ok {
+					t.Fatal("expected the payment to be validated before the callbacks")
+				}
+				paid = append(paid, p.Amount)
+			},
+			"after_refund": func(e *Transition) {
+				if _, ok := pay.Get(e); ok || e.Payload() != nil {
+					t.Error("expected no payload for an event without schema")
+				}
+			},
+		},
+		WithPayloads(pay),
+	)
+
+	instance := machine.NewInstance("unpaid")
+
+	for _, args := range [][]interface{}{
+		nil,
+		{payment{10}, payment{20}},
+		{10},
+		{payment{-5}},
+	} {
+		err := instance.Transition(machine, "pay", args...)
+
+		var payloadErr InvalidPayloadError
+		if !errors.As(err, &payloadErr) || payloadErr.Event != "pay" {
+			t.Errorf("expected InvalidPayloadError for %v, got %v", args, err)
+		}
+	}
+
+	if err := instance.Transition(machine, "pay", payment{10}); err != nil {
+		t.Fatal(err)
+	}
+	if err := instance.Transition(machine, "refund", "any", "args"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(paid) != 1 || paid[0] != 10 || !instance.Is("unpaid") {
+		t.Errorf("expected only the valid payment to reach the callbacks, got %v", paid)
+	}
+}
+
+func TestPayloadErrors(t *testing.T) {
+	pay := NewPayload[payment]("pay")
+
+	if _, err := pay.ValidatePayload(nil); err == nil || err.Error() != "expected 1 argument of type pkg.payment, got 0" {
+		t.Errorf("unexpected error %v", err)
+	}
+	if _, err := pay.ValidatePayload([]interface{}{"10"}); err == nil || err.Error() != "expected an argument of type pkg.payment, got string" {
+		t.Errorf("unexpected error %v", err)
+	}
+}
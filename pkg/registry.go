@@ -0,0 +1,314 @@
+package pkg
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRegistryShards is the default number of lock shards of a Registry.
+const DefaultRegistryShards = 32
+
+// RegistryOption configures optional behaviour of a Registry.
+type RegistryOption func(*Registry)
+
+// WithShards sets the number of lock shards the registry's instances are
+// spread over. More shards mean less contention between unrelated IDs.
+func WithShards(shards int) RegistryOption {
+	return func(registry *Registry) {
+		if shards > 0 {
+			registry.shards = make([]registryShard, shards)
+		}
+	}
+}
+
+// WithRegistryClock sets the function used to read the current time when
+// tracking idle instances. It defaults to time.Now.
+func WithRegistryClock(now func() time.Time) RegistryOption {
+	return func(registry *Registry) {
+		registry.now = now
+	}
+}
+
+// WithInstanceOptions sets options applied to the instances the registry
+// creates, before the ID option.
+func WithInstanceOptions(options ...InstanceOption) RegistryOption {
+	return func(registry *Registry) {
+		registry.instanceOptions = append(registry.instanceOptions, options...)
+	}
+}
+
+// Registry manages instances of a single machine by ID, creating them lazily
+// in the initial state when an ID is first used.
+//
+// It has to be created with NewRegistry to function properly.
+type Registry struct {
+	machine *Machine
+	initial string
+	now     func() time.Time
+
+	// instanceOptions are applied to the created instances.
+	instanceOptions []InstanceOption
+
+	// shards hold the instances, spread by the hash of their ID.
+	shards []registryShard
+}
+
+// registryShard is a subset of the registry's instances sharing one lock.
+type registryShard struct {
+	mu      sync.RWMutex
+	entries map[string]*registryEntry
+}
+
+// registryEntry is an instance stored in the registry.
+type registryEntry struct {
+	instance *Instance
+
+	// lastUsed is the unix time in nanoseconds of the last use.
+	lastUsed int64
+	// inFlight counts the dispatches and other uses running on the instance,
+	// which is never removed while it is positive.
+	inFlight int32
+}
+
+// NewRegistry returns an empty registry creating instances of machine in the
+// initial state.
+func NewRegistry(machine *Machine, initial string, options ...RegistryOption) *Registry {
+	registry := &Registry{
+		machine: machine,
+		initial: initial,
+		now:     time.Now,
+		shards:  make([]registryShard, DefaultRegistryShards),
+	}
+
+	for _, option := range options {
+		option(registry)
+	}
+
+	for i := range registry.shards {
+		registry.shards[i].entries = make(map[string]*registryEntry)
+	}
+
+	return registry
+}
+
+// Machine returns the machine of the registry's instances.
+func (r *Registry) Machine() *Machine {
+	return r.machine
+}
+
+// Get returns the instance stored with id, if any.
+func (r *Registry) Get(id string) (*Instance, bool) {
+	shard := r.shard(id)
+
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+
+	entry, ok := shard.entries[id]
+	if !ok {
+		return nil, false
+	}
+
+	return entry.instance, true
+}
+
+// GetOrCreate returns the instance stored with id, creating it in the initial
+// state if it does not exist. The instance is not evicted until release is
+// called, which has to be done once the caller is done with it.
+func (r *Registry) GetOrCreate(id string) (instance *Instance, release func()) {
+	entry := r.acquire(id)
+
+	var once sync.Once
+
+	return entry.instance, func() {
+		once.Do(func() {
+			atomic.AddInt32(&entry.inFlight, -1)
+		})
+	}
+}
+
+// Do calls fn with the instance stored with id, creating it first if needed.
+// The instance is not evicted while fn runs.
+func (r *Registry) Do(id string, fn func(instance *Instance) error) error {
+	entry := r.acquire(id)
+	defer atomic.AddInt32(&entry.inFlight, -1)
+
+	return fn(entry.instance)
+}
+
+// Dispatch initiates a state transition with the named event on the instance
+// stored with id, creating it first if needed. See Instance.Transition.
+func (r *Registry) Dispatch(id string, name string, args ...interface{}) error {
+	entry := r.acquire(id)
+	defer atomic.AddInt32(&entry.inFlight, -1)
+
+	return entry.instance.Transition(r.machine, name, args...)
+}
+
+// Remove deletes the instance stored with id and reports whether it was
+// removed. Like the evictions, it leaves the instance in place while it is in
+// use by Dispatch, Do or a GetOrCreate not released yet, so that a single
+// instance exists for an ID at any time.
+func (r *Registry) Remove(id string) bool {
+	shard := r.shard(id)
+
+	shard.mu.Lock()
+	entry, ok := shard.entries[id]
+	if ok && atomic.LoadInt32(&entry.inFlight) > 0 {
+		ok = false
+	} else if ok {
+		delete(shard.entries, id)
+	}
+	shard.mu.Unlock()
+
+	// notify without the lock, so observers can use the registry
+	if ok {
+		r.machine.instanceRemoved(entry.instance)
+	}
+
+	return ok
+}
+
+// Len returns the number of instances in the registry.
+func (r *Registry) Len() int {
+	count := 0
+
+	for i := range r.shards {
+		shard := &r.shards[i]
+
+		shard.mu.RLock()
+		count += len(shard.entries)
+		shard.mu.RUnlock()
+	}
+
+	return count
+}
+
+// Range calls fn for each instance in the registry until fn returns false.
+// Instances added or removed during the iteration may or may not be visited.
+func (r *Registry) Range(fn func(id string, instance *Instance) bool) {
+	for i := range r.shards {
+		shard := &r.shards[i]
+
+		// copy the shard so fn can use the registry without deadlocking
+		shard.mu.RLock()
+		entries := make(map[string]*Instance, len(shard.entries))
+		for id, entry := range shard.entries {
+			entries[id] = entry.instance
+		}
+		shard.mu.RUnlock()
+
+		for id, instance := range entries {
+			if !fn(id, instance) {
+				return
+			}
+		}
+	}
+}
+
+// CountByState returns the number of instances in each current state.
+func (r *Registry) CountByState() map[string]int {
+	counts := make(map[string]int)
+
+	r.Range(func(_ string, instance *Instance) bool {
+		counts[instance.Current()]++
+
+		return true
+	})
+
+	return counts
+}
+
+// EvictIdle removes the instances that have not been used for at least
+// maxIdle and returns how many were removed.
+func (r *Registry) EvictIdle(maxIdle time.Duration) int {
+	deadline := r.now().Add(-maxIdle).UnixNano()
+
+	return r.evict(func(entry *registryEntry) bool {
+		return atomic.LoadInt64(&entry.lastUsed) <= deadline
+	})
+}
+
+// EvictFinal removes the instances in a final state, one without outgoing
+// transitions, and returns how many were removed.
+func (r *Registry) EvictFinal() int {
+	return r.evict(func(entry *registryEntry) bool {
+		return r.machine.isFinal(entry.instance.Current())
+	})
+}
+
+// evict removes the entries matching fn that are not in use.
+func (r *Registry) evict(fn func(*registryEntry) bool) int {
+	count := 0
+
+	for i := range r.shards {
+		shard := &r.shards[i]
+
+		var evicted []*Instance
+
+		shard.mu.Lock()
+		for id, entry := range shard.entries {
+			if atomic.LoadInt32(&entry.inFlight) == 0 && fn(entry) {
+				delete(shard.entries, id)
+				evicted = append(evicted, entry.instance)
+			}
+		}
+		shard.mu.Unlock()
+
+		// notify without the lock, so observers can use the registry
+		for _, instance := range evicted {
+			r.machine.instanceRemoved(instance)
+		}
+		count += len(evicted)
+	}
+
+	return count
+}
+
+// acquire returns the entry stored with id, creating it if needed, and marks
+// it used and in flight so it is not removed until the caller releases it.
+func (r *Registry) acquire(id string) *registryEntry {
+	shard := r.shard(id)
+	now := r.now().UnixNano()
+
+	shard.mu.RLock()
+	entry, ok := shard.entries[id]
+	if ok {
+		atomic.AddInt32(&entry.inFlight, 1)
+		atomic.StoreInt64(&entry.lastUsed, now)
+	}
+	shard.mu.RUnlock()
+
+	if ok {
+		return entry
+	}
+
+	shard.mu.Lock()
+	entry, ok = shard.entries[id]
+	if !ok {
+		options := append(append([]InstanceOption(nil), r.instanceOptions...), WithInstanceID(id))
+		entry = &registryEntry{
+			instance: r.machine.newInstance(r.initial, options...),
+		}
+		shard.entries[id] = entry
+	}
+	atomic.AddInt32(&entry.inFlight, 1)
+	atomic.StoreInt64(&entry.lastUsed, now)
+	shard.mu.Unlock()
+
+	// notify without the lock, so observers can use the registry
+	if !ok {
+		r.machine.instanceCreated(entry.instance)
+	}
+
+	return entry
+}
+
+// shard returns the shard responsible for id.
+func (r *Registry) shard(id string) *registryShard {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(id))
+
+	return &r.shards[hash.Sum32()%uint32(len(r.shards))]
+}
@@ -0,0 +1,214 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newRideMachine() *Machine {
+	return NewMachine(
+		[]TransitionDesc{
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+			{Name: "finish", Sources: []string{"accepted"}, Destination: "finished"},
+		},
+		map[string]Callback{},
+	)
+}
+
+func TestRegistryDispatch(t *testing.T) {
+	registry := NewRegistry(newRideMachine(), "offering", WithShards(4))
+
+	if err := registry.Dispatch("ride-1", "accept"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := registry.Dispatch("ride-1", "accept"); !errors.As(err, new(InvalidEventError)) {
+		t.Errorf("expected InvalidEventError, got %v", err)
+	}
+
+	instance, ok := registry.Get("ride-1")
+	if !ok || instance.Current() != "accepted" || instance.ID() != "ride-1" {
+		t.Fatalf("expected ride-1 to be accepted")
+	}
+
+	if _, ok := registry.Get("ride-2"); ok {
+		t.Errorf("expected ride-2 not to exist")
+	}
+
+	ride2, release := registry.GetOrCreate("ride-2")
+	if ride2.Current() != "offering" {
+		t.Errorf("expected ride-2 to be created in the initial state")
+	}
+
+	if evicted := registry.EvictIdle(0); evicted != 1 {
+		t.Errorf("expected only ride-1 to be evicted while ride-2 is in use, got %d", evicted)
+	}
+	release()
+	release()
+
+	if registry.Len() != 1 {
+		t.Errorf("expected 1 instance, got %d", registry.Len())
+	}
+
+	err := registry.Do("ride-2", func(instance *Instance) error {
+		if registry.EvictIdle(0) != 0 {
+			t.Error("expected ride-2 not to be evicted while in use")
+		}
+		if registry.Remove("ride-2") {
+			t.Error("expected ride-2 not to be removed while in use")
+		}
+
+		return instance.Transition(registry.machine, "accept")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !registry.Remove("ride-2") || registry.Remove("ride-2") {
+		t.Errorf("expected ride-2 to be removed once")
+	}
+}
+
+func TestRegistryConcurrentDispatch(t *testing.T) {
+	registry := NewRegistry(newRideMachine(), "offering")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_ = registry.Dispatch(fmt.Sprintf("ride-%d", i%10), "accept")
+		}(i)
+	}
+	wg.Wait()
+
+	counts := registry.CountByState()
+	if counts["accepted"] != 10 || len(counts) != 1 {
+		t.Errorf("expected 10 accepted instances, got %v", counts)
+	}
+}
+
+func TestRegistryEviction(t *testing.T) {
+	now := time.Unix(0, 0)
+	registry := NewRegistry(newRideMachine(), "offering", WithRegistryClock(func() time.Time {
+		return now
+	}))
+
+	_ = registry.Dispatch("idle", "accept")
+
+	now = now.Add(time.Minute)
+
+	_ = registry.Dispatch("active", "accept")
+	_ = registry.Dispatch("done", "accept")
+	_ = registry.Dispatch("done", "finish")
+
+	if evicted := registry.EvictFinal(); evicted != 1 {
+		t.Errorf("expected 1 final instance evicted, got %d", evicted)
+	}
+
+	if evicted := registry.EvictIdle(30 * time.Second); evicted != 1 {
+		t.Errorf("expected 1 idle instance evicted, got %d", evicted)
+	}
+
+	var ids []string
+	registry.Range(func(id string, _ *Instance) bool {
+		ids = append(ids, id)
+
+		return true
+	})
+
+	if len(ids) != 1 || ids[0] != "active" {
+		t.Errorf("expected only the active instance to remain, got %v", ids)
+	}
+}
+
+func TestRegistryRemoveDuringDispatch(t *testing.T) {
+	var registry *Registry
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "accept", Sources: []string{"offering"}, Destination: "accepted"},
+		},
+		map[string]Callback{
+			"enter_accepted": func(e *Transition) {
+				if registry.Remove(e.Instance.ID()) {
+					t.Error("expected the instance not to be removed while dispatched to")
+				}
+			},
+		},
+	)
+	registry = NewRegistry(machine, "offering")
+
+	if err := registry.Dispatch("ride-1", "accept"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if instance, ok := registry.Get("ride-1"); !ok || instance.Current() != "accepted" {
+		t.Fatal("expected ride-1 to be kept and accepted")
+	}
+	if !registry.Remove("ride-1") {
+		t.Error("expected ride-1 to be removed once no longer in use")
+	}
+}
+
+func TestRegistryInstanceOptions(t *testing.T) {
+	now := time.Unix(0, 0)
+	var changes []MetadataChange
+
+	registry := NewRegistry(newRideMachine(), "offering",
+		WithRegistryClock(func() time.Time { return now }),
+		WithInstanceOptions(WithInstanceID("ignored"), WithMetadataHook(func(change MetadataChange) {
+			changes = append(changes, change)
+		})),
+	)
+
+	instance, release := registry.GetOrCreate("ride-1")
+	instance.SetMetadata("driver", "alice")
+	release()
+	if instance.ID() != "ride-1" || len(changes) != 1 {
+		t.Errorf("expected the options to apply to ride-1, got %s and %v", instance.ID(), changes)
+	}
+
+	now = now.Add(time.Minute)
+	_, release = registry.GetOrCreate("ride-1")
+	release()
+	if evicted := registry.EvictIdle(30 * time.Second); evicted != 0 {
+		t.Errorf("expected GetOrCreate to mark ride-1 used, got %d evicted", evicted)
+	}
+}
+
+// evictingObserver uses the registry from its notifications.
+type evictingObserver struct {
+	NopObserver
+
+	registry *Registry
+	created  []string
+	removed  []string
+}
+
+func (o *evictingObserver) InstanceCreated(_ *Machine, instance *Instance) {
+	o.created = append(o.created, instance.ID())
+	_, _ = o.registry.Get(instance.ID())
+}
+
+func (o *evictingObserver) InstanceRemoved(_ *Machine, instance *Instance) {
+	o.removed = append(o.removed, instance.ID())
+	o.registry.Remove(instance.ID())
+}
+
+func TestRegistryObserverUsesRegistry(t *testing.T) {
+	machine := newRideMachine()
+	registry := NewRegistry(machine, "offering", WithShards(1))
+	observer := &evictingObserver{registry: registry}
+	machine.AddObserver(observer)
+
+	_ = registry.Dispatch("ride-1", "accept")
+	_ = registry.Dispatch("ride-2", "accept")
+	registry.Remove("ride-1")
+	registry.EvictIdle(0)
+
+	if len(observer.created) != 2 || len(observer.removed) != 2 || registry.Len() != 0 {
+		t.Errorf("expected 2 instances created and removed, got %v and %v", observer.created, observer.removed)
+	}
+}
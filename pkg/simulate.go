@@ -0,0 +1,102 @@
+package pkg
+
+// WithPureCallbacks flags the named before_ callbacks as pure: they have no
+// side effects besides canceling the transition or writing metadata, so
+// Instance.Simulate can run them. Other names are ignored.
+func WithPureCallbacks(names ...string) MachineOption {
+	return func(machine *Machine) {
+		if machine.pureNames == nil {
+			machine.pureNames = make(map[string]bool, len(names))
+		}
+
+		for _, name := range names {
+			machine.pureNames[name] = true
+		}
+	}
+}
+
+// Simulate predicts the outcome of Transition for the named event without
+// changing the instance. It returns the destination state and the error that
+// Transition would return, checking that the event exists in the current
+// state, validating its payload and running the before_ callbacks flagged
+// with WithPureCallbacks.
+//
+// The other callbacks are not run and observers are not notified, so the
+// prediction assumes that the callbacks not flagged as pure don't cancel the
+// transition. The callbacks are given a copy of the instance, so the metadata
+// they write, through Transition.Metadata or the instance, is discarded.
+//
+// Like Transition, it waits for the running call to Transition to return and
+// must not be called from a callback.
+func (f *Instance) Simulate(machine *Machine, name string, args ...interface{}) (string, error) {
+	f.eventMu.Lock()
+	defer f.eventMu.Unlock()
+
+	f.stateMu.RLock()
+	defer f.stateMu.RUnlock()
+
+	if f.transition != nil {
+		return "", InTransitionError{name}
+	}
+
+	dst, ok := machine.transitions[TransitionKey{name, f.current}]
+	if !ok {
+		for transitionkey := range machine.transitions {
+			if transitionkey.Event == name {
+				return "", InvalidEventError{name, f.current}
+			}
+		}
+
+		return "", UnknownEventError{name}
+	}
+
+	shadow := f.shadow()
+	e := &Transition{Instance: shadow, Name: name, Src: f.current, Dst: dst, Args: args, simulated: true}
+	e.metadata = &StagedMetadata{instance: shadow, staged: make(map[string]stagedValue)}
+
+	if err := machine.validatePayload(e); err != nil {
+		return "", err
+	}
+
+	for _, key := range []callbackKey{{name, callbackBeforeTransition}, {"", callbackBeforeTransition}} {
+		fn, ok := machine.callbacks[key]
+		if !ok || !machine.pureCallbacks[key] {
+			continue
+		}
+
+		fn(e)
+
+		if e.canceled {
+			return "", CanceledError{e.Err}
+		}
+	}
+
+	if f.current == dst {
+		return dst, NoTransitionError{e.Err}
+	}
+
+	return dst, e.Err
+}
+
+// shadow returns a copy of the instance with its state and metadata, for the
+// callbacks run by Simulate. Its metadata hook is not set.
+func (f *Instance) shadow() *Instance {
+	f.metadataMu.RLock()
+	defer f.metadataMu.RUnlock()
+
+	shadow := &Instance{
+		id:              f.id,
+		current:         f.current,
+		metadata:        make(map[string]interface{}, len(f.metadata)),
+		metadataEntries: make(map[string]metadataEntry, len(f.metadataEntries)),
+		now:             f.now,
+	}
+	for key, value := range f.metadata {
+		shadow.metadata[key] = value
+	}
+	for key, entry := range f.metadataEntries {
+		shadow.metadataEntries[key] = entry
+	}
+
+	return shadow
+}
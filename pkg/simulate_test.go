@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSimulate(t *testing.T) {
+	var effects []string
+	pay := NewPayload[int]("pay")
+
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "cancel", Sources: []string{"requested", "accepted"}, Destination: "canceled"},
+			{Name: "accept", Sources: []string{"requested"}, Destination: "accepted"},
+			{Name: "pay", Sources: []string{"accepted"}, Destination: "paid"},
+			{Name: "ping", Sources: []string{"requested"}, Destination: "requested"},
+		},
+		map[string]Callback{
+			"before_cancel": func(e *Transition) {
+				e.Metadata().SetMetadata("reason", "simulated")
+				e.Instance.SetMetadata("plain", "simulated")
+				NewKey[string]("ride", "reason").Set(e.Instance, "simulated")
+				if e.Src == "accepted" {
+					e.Cancel(errors.New("too late to cancel"))
+				}
+			},
+			"before_accept": func(e *Transition) {
+				effects = append(effects, "before_accept")
+				e.Cancel()
+			},
+			"before_transition": func(e *Transition) {
+				if !e.IsSimulated() {
+					effects = append(effects, "before_transition")
+				}
+			},
+			"leave_requested": func(e *Transition) {
+				effects = append(effects, "leave_requested")
+			},
+			"after_transition": func(e *Transition) {
+				effects = append(effects, "after_transition")
+			},
+		},
+		WithPureCallbacks("before_cancel", "before_transition", "leave_requested"),
+		WithPayloads(pay),
+	)
+
+	instance := machine.NewInstance("requested")
+
+	observer := &recordingObserver{}
+	machine.AddObserver(observer)
+
+	if dst, err := instance.Simulate(machine, "cancel"); dst != "canceled" || err != nil {
+		t.Errorf("expected cancel to lead to canceled, got %q, %v", dst, err)
+	}
+	if dst, err := instance.Simulate(machine, "accept"); dst != "accepted" || err != nil {
+		t.Errorf("expected the impure before_accept not to run, got %q, %v", dst, err)
+	}
+	if dst, err := instance.Simulate(machine, "ping"); dst != "requested" || !errors.As(err, new(NoTransitionError)) {
+		t.Errorf("expected NoTransitionError, got %q, %v", dst, err)
+	}
+	if _, err := instance.Simulate(machine, "pay"); !errors.As(err, new(InvalidEventError)) {
+		t.Errorf("expected InvalidEventError, got %v", err)
+	}
+	if _, err := instance.Simulate(machine, "fly"); !errors.As(err, new(UnknownEventError)) {
+		t.Errorf("expected UnknownEventError, got %v", err)
+	}
+
+	instance.SetState("accepted")
+	if _, err := instance.Simulate(machine, "cancel"); !errors.As(err, new(CanceledError)) || err.Error() != "transition canceled with error: too late to cancel" {
+		t.Errorf("expected the guard to cancel, got %v", err)
+	}
+	if _, err := instance.Simulate(machine, "pay", "ten"); !errors.As(err, new(InvalidPayloadError)) {
+		t.Errorf("expected InvalidPayloadError, got %v", err)
+	}
+	if dst, err := instance.Simulate(machine, "pay", 10); dst != "paid" || err != nil {
+		t.Errorf("expected pay to lead to paid, got %q, %v", dst, err)
+	}
+
+	if !instance.Is("accepted") {
+		t.Errorf("expected the state to be unchanged, got %s", instance.Current())
+	}
+	for _, key := range []string{"reason", "plain", "ride/reason"} {
+		if _, ok := instance.GetMetadata(key); ok {
+			t.Errorf("expected the metadata to be unchanged, got %s", key)
+		}
+	}
+	if len(effects) != 0 {
+		t.Errorf("expected no side effects, got %v", effects)
+	}
+	if len(observer.events) != 0 {
+		t.Errorf("expected observers not to be notified, got %v", observer.events)
+	}
+}
+
+func TestSimulateDuringTransition(t *testing.T) {
+	machine := NewMachine(
+		[]TransitionDesc{
+			{Name: "toggle", Sources: []string{"on"}, Destination: "off"},
+			{Name: "toggle", Sources: []string{"off"}, Destination: "on"},
+		},
+		map[string]Callback{},
+	)
+	instance := machine.NewInstance("on")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		for i := 0; i < 100; i++ {
+			_ = instance.Transition(machine, "toggle")
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		if _, err := instance.Simulate(machine, "toggle"); err != nil {
+			t.Errorf("expected toggle to be possible, got %v", err)
+		}
+	}
+	<-done
+}
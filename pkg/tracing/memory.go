@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+)
+
+// RecordedSpan is a span recorded by MemoryTracer.
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Links      []*RecordedSpan
+	Attributes map[string]string
+	Err        error
+	Ended      bool
+
+	tracer *MemoryTracer
+}
+
+// SetAttributes implements Span.
+func (s *RecordedSpan) SetAttributes(attributes ...Attribute) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	for _, attribute := range attributes {
+		s.Attributes[attribute.Key] = attribute.Value
+	}
+}
+
+// RecordError implements Span.
+func (s *RecordedSpan) RecordError(err error) {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.Err = err
+}
+
+// End implements Span.
+func (s *RecordedSpan) End() {
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+
+	s.Ended = true
+}
+
+// MemoryTracer is a Tracer recording spans in memory, meant for tests.
+type MemoryTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// spanKey is the context key of the span started by MemoryTracer.
+type spanKey struct{}
+
+// NewMemoryTracer returns a tracer without recorded spans.
+func NewMemoryTracer() *MemoryTracer {
+	return &MemoryTracer{}
+}
+
+// Start implements Tracer.
+func (m *MemoryTracer) Start(ctx context.Context, name string, links ...context.Context) (context.Context, Span) {
+	span := &RecordedSpan{
+		Name:       name,
+		Attributes: make(map[string]string),
+		tracer:     m,
+	}
+
+	span.Parent, _ = ctx.Value(spanKey{}).(*RecordedSpan)
+
+	for _, link := range links {
+		if linked, ok := link.Value(spanKey{}).(*RecordedSpan); ok {
+			span.Links = append(span.Links, linked)
+		}
+	}
+
+	m.mu.Lock()
+	m.spans = append(m.spans, span)
+	m.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+// Spans returns the recorded spans in the order they were started.
+func (m *MemoryTracer) Spans() []*RecordedSpan {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]*RecordedSpan(nil), m.spans...)
+}
@@ -0,0 +1,228 @@
+// Package tracing provides an fsm.Observer creating OpenTelemetry-style spans
+// around transitions and their callbacks.
+//
+// The tracer is abstracted by the Tracer interface, which a thin adapter over
+// an OpenTelemetry trace.Tracer satisfies, and MemoryTracer records spans in
+// memory for tests.
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+// Attribute keys set on the spans.
+const (
+	AttributeMachine = "fsm.machine"
+	AttributeEvent   = "fsm.event"
+	AttributeSrc     = "fsm.src"
+	AttributeDst     = "fsm.dst"
+	AttributeOutcome = "fsm.outcome"
+	AttributeHook    = "fsm.hook"
+	AttributeAsync   = "fsm.async"
+)
+
+// Span names.
+const (
+	// SpanTransition is the name of the span around a transition.
+	SpanTransition = "fsm.Transition"
+	// SpanCompleteTransition is the name of the span around the completion of
+	// an asynchronous transition.
+	SpanCompleteTransition = "fsm.CompleteTransition"
+)
+
+// Attribute is a key value pair describing a span.
+type Attribute struct {
+	Key   string
+	Value string
+}
+
+// Span is a unit of work started by a Tracer.
+type Span interface {
+	// SetAttributes sets attributes on the span.
+	SetAttributes(attributes ...Attribute)
+
+	// RecordError marks the span as failed with err.
+	RecordError(err error)
+
+	// End completes the span.
+	End()
+}
+
+// Tracer starts spans.
+type Tracer interface {
+	// Start starts a span as a child of the span in ctx, if any, and returns a
+	// context holding the new span. Links are contexts holding spans that are
+	// causally related to the new one without being its parent.
+	Start(ctx context.Context, name string, links ...context.Context) (context.Context, Span)
+}
+
+// Option configures optional behaviour of an Observer.
+type Option func(*Observer)
+
+// WithContext sets the function returning the parent context of the span of a
+// transition. By default, the first argument of the transition that is a
+// context.Context is used.
+func WithContext(fn func(t *fsm.Transition) context.Context) Option {
+	return func(o *Observer) {
+		o.context = fn
+	}
+}
+
+// Observer is an fsm.Observer creating a span per transition with child spans
+// per callback. The completion of an asynchronous transition gets its own span
+// linked to the leave_ callback that started it.
+//
+// It has to be created with New and added to machines with fsm.WithObserver or
+// Machine.AddObserver.
+type Observer struct {
+	fsm.NopObserver
+
+	tracer  Tracer
+	context func(t *fsm.Transition) context.Context
+
+	// mu guards the spans below.
+	mu sync.Mutex
+	// active holds the spans of the transitions being processed.
+	active map[*fsm.Transition]*transitionSpans
+	// async holds the contexts of the leave_ callbacks that started an
+	// asynchronous transition, until it is completed.
+	async map[*fsm.Transition]context.Context
+}
+
+// transitionSpans are the open spans of a transition.
+type transitionSpans struct {
+	ctx  context.Context
+	span Span
+
+	// callbackCtx and callback are the span of the running callback.
+	callbackCtx context.Context
+	callback    Span
+}
+
+// New returns an observer starting spans with tracer.
+func New(tracer Tracer, options ...Option) *Observer {
+	o := &Observer{
+		tracer:  tracer,
+		context: argumentContext,
+		active:  make(map[*fsm.Transition]*transitionSpans),
+		async:   make(map[*fsm.Transition]context.Context),
+	}
+
+	for _, option := range options {
+		option(o)
+	}
+
+	return o
+}
+
+// TransitionStarted implements fsm.Observer.
+func (o *Observer) TransitionStarted(machine *fsm.Machine, t *fsm.Transition) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	name := SpanTransition
+
+	var links []context.Context
+	if leave, ok := o.async[t]; ok && t.IsAsync() {
+		name = SpanCompleteTransition
+		links = append(links, leave)
+		delete(o.async, t)
+	}
+
+	ctx, span := o.tracer.Start(o.context(t), name, links...)
+	span.SetAttributes(
+		Attribute{AttributeMachine, machine.Name()},
+		Attribute{AttributeEvent, t.Name},
+		Attribute{AttributeSrc, t.Src},
+		Attribute{AttributeDst, t.Dst},
+	)
+
+	if len(links) > 0 {
+		span.SetAttributes(Attribute{AttributeAsync, "true"})
+	}
+
+	o.active[t] = &transitionSpans{ctx: ctx, span: span}
+}
+
+// CallbackStarted implements fsm.Observer.
+func (o *Observer) CallbackStarted(_ *fsm.Machine, t *fsm.Transition, hook fsm.Hook) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spans, ok := o.active[t]
+	if !ok {
+		return
+	}
+
+	spans.callbackCtx, spans.callback = o.tracer.Start(spans.ctx, hook.String())
+	spans.callback.SetAttributes(Attribute{AttributeHook, string(hook.Type)})
+}
+
+// CallbackFinished implements fsm.Observer.
+func (o *Observer) CallbackFinished(_ *fsm.Machine, t *fsm.Transition, hook fsm.Hook, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spans, ok := o.active[t]
+	if !ok || spans.callback == nil {
+		return
+	}
+
+	spans.callback.End()
+
+	if hook.Type == fsm.HookLeaveState && t.IsAsync() {
+		o.async[t] = spans.callbackCtx
+	}
+
+	spans.callbackCtx, spans.callback = nil, nil
+}
+
+// TransitionFinished implements fsm.Observer.
+func (o *Observer) TransitionFinished(_ *fsm.Machine, t *fsm.Transition, err error, _ time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	spans, ok := o.active[t]
+	if !ok {
+		return
+	}
+
+	delete(o.active, t)
+
+	outcome := fsm.Outcome(err)
+	spans.span.SetAttributes(Attribute{AttributeOutcome, outcome})
+
+	if outcome != fsm.OutcomeOK && outcome != fsm.OutcomeNoTransition && outcome != fsm.OutcomeAsync {
+		spans.span.RecordError(err)
+	}
+
+	spans.span.End()
+}
+
+// InstanceRemoved implements fsm.Observer, forgetting the asynchronous
+// transition of the instance that will never be completed.
+func (o *Observer) InstanceRemoved(_ *fsm.Machine, instance *fsm.Instance) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for t := range o.async {
+		if t.Instance == instance {
+			delete(o.async, t)
+		}
+	}
+}
+
+// argumentContext returns the first argument of t that is a context.Context.
+func argumentContext(t *fsm.Transition) context.Context {
+	for _, arg := range t.Args {
+		if ctx, ok := arg.(context.Context); ok {
+			return ctx
+		}
+	}
+
+	return context.Background()
+}
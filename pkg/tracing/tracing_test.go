@@ -0,0 +1,114 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	fsm "github.com/snapp-incubator/fsm/pkg"
+)
+
+func TestTransitionSpans(t *testing.T) {
+	tracer := NewMemoryTracer()
+
+	machine := fsm.NewMachine(
+		[]fsm.TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+		},
+		map[string]fsm.Callback{
+			"before_open": func(t *fsm.Transition) {},
+			"enter_state": func(t *fsm.Transition) {},
+			"before_close": func(t *fsm.Transition) {
+				t.Cancel(errors.New("jammed"))
+			},
+		},
+		fsm.WithName("door"),
+		fsm.WithObserver(New(tracer)),
+	)
+
+	instance := machine.NewInstance("closed")
+
+	parentCtx, parent := tracer.Start(context.Background(), "request")
+	_ = instance.Transition(machine, "open", parentCtx)
+	_ = instance.Transition(machine, "close")
+
+	spans := tracer.Spans()
+	if len(spans) != 6 {
+		t.Fatalf("expected 6 spans, got %d", len(spans))
+	}
+
+	open, before, enter, closing := spans[1], spans[2], spans[3], spans[4]
+
+	if open.Name != SpanTransition || open.Parent != parent {
+		t.Errorf("expected the transition span to be a child of the context argument")
+	}
+
+	expected := map[string]string{
+		AttributeMachine: "door",
+		AttributeEvent:   "open",
+		AttributeSrc:     "closed",
+		AttributeDst:     "open",
+		AttributeOutcome: fsm.OutcomeOK,
+	}
+	for key, value := range expected {
+		if open.Attributes[key] != value {
+			t.Errorf("expected attribute %s to be %s, got %s", key, value, open.Attributes[key])
+		}
+	}
+
+	if before.Name != "before_open" || before.Parent != open || !before.Ended {
+		t.Errorf("expected an ended before_open span under the transition span")
+	}
+
+	if enter.Name != "enter_state" || enter.Attributes[AttributeHook] != "enter" {
+		t.Errorf("expected an enter_state span, got %s", enter.Name)
+	}
+
+	if closing.Attributes[AttributeOutcome] != fsm.OutcomeCanceled || closing.Err == nil || !closing.Ended {
+		t.Errorf("expected the canceled transition span to record its error")
+	}
+}
+
+func TestAsyncCompletionLink(t *testing.T) {
+	tracer := NewMemoryTracer()
+
+	machine := fsm.NewMachine(
+		[]fsm.TransitionDesc{
+			{Name: "start", Sources: []string{"idle"}, Destination: "running"},
+		},
+		map[string]fsm.Callback{
+			"leave_idle": func(t *fsm.Transition) {
+				t.Async()
+			},
+		},
+		fsm.WithObserver(New(tracer)),
+	)
+
+	instance := machine.NewInstance("idle")
+
+	_ = instance.Transition(machine, "start")
+
+	if err := instance.CompleteTransition(machine); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+
+	start, leave, complete := spans[0], spans[1], spans[2]
+
+	if start.Attributes[AttributeOutcome] != fsm.OutcomeAsync || start.Err != nil {
+		t.Errorf("expected the starting span to end with the async outcome")
+	}
+
+	if complete.Name != SpanCompleteTransition || len(complete.Links) != 1 || complete.Links[0] != leave {
+		t.Errorf("expected the completion span to link to the leave_idle span")
+	}
+
+	if complete.Attributes[AttributeOutcome] != fsm.OutcomeOK || complete.Attributes[AttributeAsync] != "true" {
+		t.Errorf("unexpected completion attributes %v", complete.Attributes)
+	}
+}
@@ -19,11 +19,25 @@ type Transition struct {
 	// Args is an optional list of arguments passed to the callback.
 	Args []interface{}
 
+	// payload is the argument validated by the payload schema of the event.
+	payload interface{}
+
+	// metadata stages the metadata written during the transition.
+	metadata *StagedMetadata
+
 	// canceled is an internal flag set if the transition is canceled.
 	canceled bool
 
 	// async is an internal flag set if the transition should be asynchronous
 	async bool
+
+	// simulated is an internal flag set if the transition is run by
+	// Instance.Simulate.
+	simulated bool
+
+	// correlation is shared with the transitions of events raised during
+	// the same call to Instance.Transition.
+	correlation *correlation
 }
 
 // Cancel can be called in before_<Transition> or leave_<STATE> to cancel the
@@ -40,12 +54,55 @@ func (t *Transition) Cancel(err ...error) {
 // Async can be called in leave_<STATE> to do an asynchronous state transition.
 //
 // The current state transition will be on hold in the old state until a final
-// call to Transition is made. This will complete the transition and possibly
-// call the other callbacks.
+// call to CompleteTransition is made. This will complete the transition and
+// possibly call the other callbacks.
 func (t *Transition) Async() {
 	t.async = true
 }
 
+// CorrelationID returns an ID shared by the transitions processed in the same
+// call to Instance.Transition, including the events raised by its callbacks.
+// The completion of an asynchronous transition keeps the ID it started with.
+func (t *Transition) CorrelationID() string {
+	if t.correlation == nil {
+		return ""
+	}
+
+	return t.correlation.ID()
+}
+
+// Payload returns the payload validated by the schema registered for the
+// event with WithPayloads, nil if there is none. Payload.Get returns it typed.
+func (t *Transition) Payload() interface{} {
+	return t.payload
+}
+
+// Metadata returns the view of the transition on the metadata of the
+// instance. Writes made from before_ and leave_ callbacks, through it or
+// directly on the instance, are only committed if the transition is not
+// canceled. Reads see the staged writes.
+//
+// For a transition not run by an instance, like one built by hand in a test,
+// the view reads and writes the metadata of t.Instance directly.
+func (t *Transition) Metadata() *StagedMetadata {
+	if t.metadata == nil {
+		return &StagedMetadata{instance: t.Instance, committed: true}
+	}
+
+	return t.metadata
+}
+
+// IsSimulated returns true if the transition is a dry run by Instance.Simulate,
+// for pure callbacks that need to tell.
+func (t *Transition) IsSimulated() bool {
+	return t.simulated
+}
+
+// IsAsync returns true if Async was called on the transition.
+func (t *Transition) IsAsync() bool {
+	return t.async
+}
+
 // TransitionDesc represents an event when initializing the FSM.
 //
 // The event can have one or more source states that is valid for performing
@@ -53,22 +110,23 @@ func (t *Transition) Async() {
 // the specified destination state, calling all defined callbacks as it goes.
 type TransitionDesc struct {
 	// Name is the event name used when calling for a transition.
-	Name string
+	Name string `json:"name"`
 
 	// Sources is a slice of source states that the FSM must be in to perform a state transition.
-	Sources []string
+	Sources []string `json:"sources"`
 
 	// Destination is the destination state that the FSM will be in if the transition succeeds.
-	Destination string
+	Destination string `json:"destination"`
 }
 
-// transitionKey is a struct key used for storing the transition map.
-type transitionKey struct {
-	// name of the transition that the keys refers to.
-	name string
+// TransitionKey identifies a transition of a machine: an event in a source
+// state. It is the key of the transition map.
+type TransitionKey struct {
+	// Event is the name of the transition that the key refers to.
+	Event string
 
-	// source from where the transition can transition.
-	source string
+	// Src is the state from where the transition can transition.
+	Src string
 }
 
 // transitioner is an interface for the FSM's transition function.
@@ -17,47 +17,71 @@ const (
 	MermaidStateDiagram VisualizeType = "mermaid-state-diagram"
 	// MermaidFlowChart the type for mermaid output (https://mermaid-js.github.io/mermaid/#/flowchart) in the flow chart form
 	MermaidFlowChart VisualizeType = "mermaid-flow-chart"
+	// PLANTUML the type for PlantUML output (https://plantuml.com/state-diagram) in the state diagram form
+	PLANTUML VisualizeType = "plantuml"
+	// D2 the type for D2 output (https://d2lang.com)
+	D2 VisualizeType = "d2"
+	// ASCII the type for plain text output drawn with ASCII characters
+	ASCII VisualizeType = "ascii"
+	// UNICODE the type for plain text output drawn with Unicode box-drawing characters
+	UNICODE VisualizeType = "unicode"
+	// SVG the type for a self-contained SVG image
+	SVG VisualizeType = "svg"
+	// HTML the type for a standalone HTML page embedding the SVG image
+	HTML VisualizeType = "html"
 )
 
 // VisualizeWithType outputs a visualization of a FSM in the desired format.
 // If the type is not given it defaults to GRAPHVIZ
-func VisualizeWithType(machine *Machine, fsm *Instance, visualizeType VisualizeType) (string, error) {
+func VisualizeWithType(machine *Machine, fsm *Instance, visualizeType VisualizeType, options ...VisualizeOption) (string, error) {
 	switch visualizeType {
 	case GRAPHVIZ:
-		return Visualize(machine, fsm), nil
+		return Visualize(machine, fsm, options...), nil
 	case MERMAID:
-		return VisualizeForMermaidWithGraphType(machine, fsm, StateDiagram)
+		return VisualizeForMermaidWithGraphType(machine, fsm, StateDiagram, options...)
 	case MermaidStateDiagram:
-		return VisualizeForMermaidWithGraphType(machine, fsm, StateDiagram)
+		return VisualizeForMermaidWithGraphType(machine, fsm, StateDiagram, options...)
 	case MermaidFlowChart:
-		return VisualizeForMermaidWithGraphType(machine, fsm, FlowChart)
+		return VisualizeForMermaidWithGraphType(machine, fsm, FlowChart, options...)
+	case PLANTUML:
+		return VisualizeForPlantUML(machine, fsm, options...), nil
+	case D2:
+		return VisualizeForD2(machine, fsm, options...), nil
+	case ASCII:
+		return VisualizeAsTextWithCharset(machine, fsm, ASCIICharset, options...)
+	case UNICODE:
+		return VisualizeAsTextWithCharset(machine, fsm, UnicodeCharset, options...)
+	case SVG:
+		return VisualizeAsSVG(machine, fsm, options...), nil
+	case HTML:
+		return VisualizeAsHTML(machine, fsm, options...), nil
 	default:
 		return "", fmt.Errorf("unknown VisualizeType: %s", visualizeType)
 	}
 }
 
-func getSortedTransitionKeys(transitions map[transitionKey]string) []transitionKey {
+func getSortedTransitionKeys(transitions map[TransitionKey]string) []TransitionKey {
 	// we sort the key alphabetically to have a reproducible graph output
-	sortedTransitionKeys := make([]transitionKey, 0)
+	sortedTransitionKeys := make([]TransitionKey, 0)
 
 	for transition := range transitions {
 		sortedTransitionKeys = append(sortedTransitionKeys, transition)
 	}
 	sort.Slice(sortedTransitionKeys, func(i, j int) bool {
-		if sortedTransitionKeys[i].source == sortedTransitionKeys[j].source {
-			return sortedTransitionKeys[i].name < sortedTransitionKeys[j].name
+		if sortedTransitionKeys[i].Src == sortedTransitionKeys[j].Src {
+			return sortedTransitionKeys[i].Event < sortedTransitionKeys[j].Event
 		}
-		return sortedTransitionKeys[i].source < sortedTransitionKeys[j].source
+		return sortedTransitionKeys[i].Src < sortedTransitionKeys[j].Src
 	})
 
 	return sortedTransitionKeys
 }
 
-func getSortedStates(transitions map[transitionKey]string) ([]string, map[string]string) {
+func getSortedStates(transitions map[TransitionKey]string) ([]string, map[string]string) {
 	statesToIDMap := make(map[string]string)
 	for transition, target := range transitions {
-		if _, ok := statesToIDMap[transition.source]; !ok {
-			statesToIDMap[transition.source] = ""
+		if _, ok := statesToIDMap[transition.Src]; !ok {
+			statesToIDMap[transition.Src] = ""
 		}
 		if _, ok := statesToIDMap[target]; !ok {
 			statesToIDMap[target] = ""
@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// d2Directions maps the layout directions to D2's.
+var d2Directions = map[Direction]string{
+	TopToBottom: "down",
+	LeftToRight: "right",
+	BottomToTop: "up",
+	RightToLeft: "left",
+}
+
+// VisualizeForD2 outputs a visualization of a FSM in D2 format.
+func VisualizeForD2(machine *Machine, fsm *Instance, options ...VisualizeOption) string {
+	var buf bytes.Buffer
+
+	d := newDiagram(machine, fsm, options)
+
+	direction, ok := d2Directions[d.config.direction]
+	if !ok {
+		direction = d2Directions[LeftToRight]
+	}
+
+	buf.WriteString(fmt.Sprintf("direction: %s\n\n", direction))
+	writeD2States(&buf, d, d.unclusteredStates(), "")
+	writeD2Clusters(&buf, d)
+	buf.WriteString("\n")
+	writeD2Transitions(&buf, d)
+
+	return buf.String()
+}
+
+func writeD2States(buf *bytes.Buffer, d *diagram, states []diagramState, indent string) {
+	for _, state := range states {
+		if style := d2Style(d.fillColor(state, highlightingColor), state.style); style != "" {
+			buf.WriteString(fmt.Sprintf(`%s%s: "%s" {%s}`, indent, state.id, escapeD2(state.label), style))
+		} else {
+			buf.WriteString(fmt.Sprintf(`%s%s: "%s"`, indent, state.id, escapeD2(state.label)))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+func writeD2Clusters(buf *bytes.Buffer, d *diagram) {
+	for _, cluster := range d.clusters {
+		buf.WriteString(fmt.Sprintf(`%s: "%s" {`, cluster.id, escapeD2(cluster.name)))
+		buf.WriteString("\n")
+		writeD2States(buf, d, cluster.states, "  ")
+		buf.WriteString("}\n")
+	}
+}
+
+func writeD2Transitions(buf *bytes.Buffer, d *diagram) {
+	// clustered states are referred to through their container
+	paths := make(map[string]string, len(d.states))
+	for _, state := range d.states {
+		paths[state.name] = state.id
+	}
+	for _, cluster := range d.clusters {
+		for _, state := range cluster.states {
+			paths[state.name] = cluster.id + "." + state.id
+		}
+	}
+
+	for _, edge := range d.edges {
+		var properties []string
+		if edge.color != "" {
+			properties = append(properties, fmt.Sprintf(`style.stroke: "%s"`, escapeD2(edge.color)))
+		}
+		if edge.width != 0 {
+			properties = append(properties, fmt.Sprintf(`style.stroke-width: %d`, edge.width))
+		}
+
+		if len(properties) > 0 {
+			buf.WriteString(fmt.Sprintf(`%s -> %s: "%s" {%s}`, paths[edge.source], paths[edge.destination], escapeD2(edge.label), strings.Join(properties, "; ")))
+		} else {
+			buf.WriteString(fmt.Sprintf(`%s -> %s: "%s"`, paths[edge.source], paths[edge.destination], escapeD2(edge.label)))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+// d2Style returns the D2 style properties of a state.
+func d2Style(fill string, style StateStyle) string {
+	var properties []string
+	if fill != "" {
+		properties = append(properties, fmt.Sprintf(`style.fill: "%s"`, escapeD2(fill)))
+	}
+	if style.BorderColor != "" {
+		properties = append(properties, fmt.Sprintf(`style.stroke: "%s"`, escapeD2(style.BorderColor)))
+	}
+	if style.TextColor != "" {
+		properties = append(properties, fmt.Sprintf(`style.font-color: "%s"`, escapeD2(style.TextColor)))
+	}
+
+	return strings.Join(properties, "; ")
+}
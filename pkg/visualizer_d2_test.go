@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestD2Output(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+			{Name: "part-close", Sources: []string{"intermediate"}, Destination: "closed"},
+		},
+		map[string]Callback{},
+	)
+
+	i := machineUnderTest.NewInstance("closed")
+
+	got, err := VisualizeWithType(machineUnderTest, i, D2)
+	if err != nil {
+		t.Errorf("got error for visualizing with type D2: %s", err)
+	}
+	wanted := `
+direction: right
+
+id0: "closed" {style.fill: "#00AA00"}
+id1: "intermediate"
+id2: "open"
+
+id0 -> id2: "open"
+id1 -> id0: "part-close"
+id2 -> id0: "close"
+`
+	normalizedGot := strings.ReplaceAll(got, "\n", "")
+	normalizedWanted := strings.ReplaceAll(wanted, "\n", "")
+	if normalizedGot != normalizedWanted {
+		t.Errorf("build d2 graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+		fmt.Println([]byte(normalizedGot))
+		fmt.Println([]byte(normalizedWanted))
+	}
+}
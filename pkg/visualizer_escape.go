@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// escapeRunes returns s with the runes for which safe returns false replaced
+// by encode. If trimmed is set, spaces at either end of s are replaced too, for
+// the formats trimming their labels.
+func escapeRunes(s string, trimmed bool, safe func(r rune) bool, encode func(r rune) string) string {
+	var builder strings.Builder
+
+	runes := []rune(s)
+	for i, r := range runes {
+		atEdge := i == 0 || i == len(runes)-1
+		if safe(r) && !(trimmed && r == ' ' && atEdge) {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteString(encode(r))
+		}
+	}
+
+	return builder.String()
+}
+
+// isWordRune returns true for letters, digits and combining marks.
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// escapeGraphviz escapes s for use in a double-quoted Graphviz string.
+// Control characters and ampersands are written as HTML entities.
+func escapeGraphviz(s string) string {
+	return escapeRunes(s, false, func(r rune) bool {
+		return !unicode.IsControl(r) && r != '"' && r != '\\' && r != '&'
+	}, func(r rune) string {
+		if r == '"' || r == '\\' {
+			return `\` + string(r)
+		}
+
+		return fmt.Sprintf("&#%d;", r)
+	})
+}
+
+// escapeMermaid escapes s for use as a Mermaid label, writing every rune that
+// could be taken for syntax as an entity code.
+func escapeMermaid(s string) string {
+	return escapeRunes(s, true, func(r rune) bool {
+		return isWordRune(r) || strings.ContainsRune(" -_.,/!?@'+=^$", r)
+	}, func(r rune) string {
+		return fmt.Sprintf("#%d;", r)
+	})
+}
+
+// mermaidStateIDPattern matches the states that can be used as is in a Mermaid
+// state diagram, excluding the IDs generated for the other states and for the
+// clusters.
+var mermaidStateIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+var mermaidGeneratedIDPattern = regexp.MustCompile(`^(id|cluster)[0-9]+$`)
+
+// mermaidKeywords can not be used as state IDs in a Mermaid state diagram.
+var mermaidKeywords = map[string]bool{
+	"state": true, "note": true, "direction": true, "class": true, "classDef": true,
+	"style": true, "as": true, "end": true, "click": true, "hide": true,
+}
+
+// isMermaidStateID returns true if state can be written as is in a Mermaid
+// state diagram.
+func isMermaidStateID(state string) bool {
+	return mermaidStateIDPattern.MatchString(state) &&
+		!mermaidGeneratedIDPattern.MatchString(state) &&
+		!mermaidKeywords[state]
+}
+
+// escapePlantUML escapes s for use in a PlantUML label, writing every rune that
+// could be taken for syntax or markup as an HTML entity.
+func escapePlantUML(s string) string {
+	return escapeRunes(s, true, func(r rune) bool {
+		return isWordRune(r) || strings.ContainsRune(" -_.,/!?@+=^$:;()[]{}%|", r)
+	}, func(r rune) string {
+		return fmt.Sprintf("&#%d;", r)
+	})
+}
+
+// d2Escaper escapes a double-quoted D2 string.
+var d2Escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `\$`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// escapeD2 escapes s for use in a double-quoted D2 string.
+func escapeD2(s string) string {
+	return escapeRunes(d2Escaper.Replace(s), false, func(r rune) bool {
+		return !unicode.IsControl(r)
+	}, func(r rune) string {
+		return fmt.Sprintf(`\u%04x`, r)
+	})
+}
+
+// escapeXML escapes s for use in XML text and attributes, writing line breaks
+// and tabs as character references to keep them in attributes. Characters
+// which are not allowed in XML are replaced by U+FFFD.
+func escapeXML(s string) string {
+	return escapeRunes(s, false, func(r rune) bool {
+		return isXMLRune(r) && !strings.ContainsRune("&<>\"'\t\n\r", r)
+	}, func(r rune) string {
+		if !isXMLRune(r) {
+			r = unicode.ReplacementChar
+		}
+
+		return fmt.Sprintf("&#%d;", r)
+	})
+}
+
+// isXMLRune returns true if r is allowed in an XML document.
+func isXMLRune(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		r >= 0x20 && r <= 0xD7FF || r >= 0xE000 && r <= 0xFFFD || r >= 0x10000 && r <= unicode.MaxRune
+}
+
+// escapeText escapes the runes of s that would break a text visualization.
+func escapeText(s string) string {
+	return escapeRunes(s, false, unicode.IsPrint, func(r rune) string {
+		return fmt.Sprintf(`\u%04x`, r)
+	})
+}
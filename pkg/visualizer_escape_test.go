@@ -0,0 +1,386 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// visualizedEdge is a transition read back from a visualization.
+type visualizedEdge struct {
+	source, name, destination string
+}
+
+// entityPattern and htmlEntityPattern match the entity codes written by the
+// escape functions for Mermaid and the other formats.
+var (
+	entityPattern     = regexp.MustCompile(`#([0-9]+);`)
+	htmlEntityPattern = regexp.MustCompile(`&#([0-9]+);`)
+)
+
+func decodeWith(pattern *regexp.Regexp, s string) string {
+	return pattern.ReplaceAllStringFunc(s, func(entity string) string {
+		code, _ := strconv.Atoi(pattern.FindStringSubmatch(entity)[1])
+
+		return string(rune(code))
+	})
+}
+
+func decodeEntities(s string) string {
+	return decodeWith(entityPattern, s)
+}
+
+func decodeHTMLEntities(s string) string {
+	return decodeWith(htmlEntityPattern, s)
+}
+
+// checkLabel fails if label contains runes outside safe once entities are
+// removed, or spaces at either end.
+func checkLabel(t *testing.T, format, label, safe string, pattern *regexp.Regexp) {
+	t.Helper()
+
+	if strings.TrimSpace(label) != label {
+		t.Errorf("%s label %q has spaces at either end", format, label)
+	}
+
+	for _, r := range pattern.ReplaceAllString(label, "") {
+		if !isWordRune(r) && !strings.ContainsRune(safe, r) {
+			t.Errorf("%s label %q contains unsafe rune %q", format, label, r)
+		}
+	}
+}
+
+var (
+	graphvizString = `"((?:[^"\\]|\\.)*)"`
+	graphvizEdge   = regexp.MustCompile(`^    ` + graphvizString + ` -> ` + graphvizString + ` \[ label = ` + graphvizString + ` \];$`)
+	graphvizState  = regexp.MustCompile(`^    ` + graphvizString + `;$`)
+	graphvizLabel  = regexp.MustCompile(`^    label = ` + graphvizString + `;$`)
+	graphvizGroup  = regexp.MustCompile(`^    subgraph "cluster[0-9]+" \{$`)
+	graphvizEscape = regexp.MustCompile(`\\(.)`)
+)
+
+func decodeGraphviz(s string) string {
+	return decodeHTMLEntities(graphvizEscape.ReplaceAllString(s, "$1"))
+}
+
+func parseGraphviz(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	inCluster := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if inCluster && line == "    }" {
+			inCluster = false
+
+			continue
+		} else if inCluster {
+			line = strings.TrimPrefix(line, "    ")
+		}
+
+		if match := graphvizEdge.FindStringSubmatch(line); match != nil {
+			for _, s := range match[1:] {
+				if strings.ContainsAny(s, "\n\r") {
+					t.Errorf("graphviz string %q contains a line break", s)
+				}
+			}
+			edges = append(edges, visualizedEdge{decodeGraphviz(match[1]), decodeGraphviz(match[3]), decodeGraphviz(match[2])})
+		} else if !inCluster && graphvizGroup.MatchString(line) {
+			inCluster = true
+		} else if inCluster && graphvizLabel.MatchString(line) {
+			continue
+		} else if line != "digraph fsm {" && line != "" && line != "}" && !graphvizState.MatchString(line) {
+			t.Errorf("unexpected graphviz line %q", line)
+		}
+	}
+
+	return edges
+}
+
+var (
+	stateDiagramDeclaration = regexp.MustCompile(`^    state "([^"]*)" as (id[0-9]+)$`)
+	stateDiagramCluster     = regexp.MustCompile(`^    state "([^"]*)" as (cluster[0-9]+)$`)
+	stateDiagramComposite   = regexp.MustCompile(`^    state (cluster[0-9]+) \{$`)
+	stateDiagramBareState   = regexp.MustCompile(`^    ([A-Za-z0-9_]+)$`)
+	stateDiagramInitial     = regexp.MustCompile(`^    \[\*\] --> ([A-Za-z0-9_]+)$`)
+	stateDiagramEdge        = regexp.MustCompile(`^    ([A-Za-z0-9_]+) --> ([A-Za-z0-9_]+): (.*)$`)
+)
+
+func parseStateDiagram(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	states := make(map[string]string)
+	clusters := make(map[string]bool)
+	state := func(id string) string {
+		if clusters[id] {
+			t.Errorf("state diagram state ID %q is a cluster ID", id)
+		}
+
+		if name, ok := states[id]; ok {
+			return name
+		}
+
+		if !isMermaidStateID(id) {
+			t.Errorf("undeclared state diagram ID %q", id)
+		}
+
+		return id
+	}
+
+	inCluster := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if inCluster && line == "    }" {
+			inCluster = false
+
+			continue
+		} else if inCluster {
+			line = strings.TrimPrefix(line, "    ")
+		}
+
+		if match := stateDiagramCluster.FindStringSubmatch(line); match != nil && !inCluster {
+			checkLabel(t, "state diagram", match[1], " -_.,/!?@'+=^$", entityPattern)
+			clusters[match[2]] = true
+		} else if match := stateDiagramComposite.FindStringSubmatch(line); match != nil && !inCluster {
+			if !clusters[match[1]] {
+				t.Errorf("undeclared state diagram cluster %q", match[1])
+			}
+			inCluster = true
+		} else if match := stateDiagramBareState.FindStringSubmatch(line); match != nil && inCluster {
+			state(match[1])
+		} else if match := stateDiagramDeclaration.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "state diagram", match[1], " -_.,/!?@'+=^$", entityPattern)
+			states[match[2]] = decodeEntities(match[1])
+		} else if match := stateDiagramEdge.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "state diagram", match[3], " -_.,/!?@'+=^$", entityPattern)
+			edges = append(edges, visualizedEdge{state(match[1]), decodeEntities(match[3]), state(match[2])})
+		} else if match := stateDiagramInitial.FindStringSubmatch(line); match != nil {
+			state(match[1])
+		} else if line != "stateDiagram-v2" {
+			t.Errorf("unexpected state diagram line %q", line)
+		}
+	}
+
+	return edges
+}
+
+var (
+	flowChartState     = regexp.MustCompile(`^    (id[0-9]+)\[(.*)\]$`)
+	flowChartEdge      = regexp.MustCompile(`^    (id[0-9]+) --> \|(.*)\| (id[0-9]+)$`)
+	flowChartHighlight = regexp.MustCompile(`^    style id[0-9]+ fill:#00AA00$`)
+	flowChartSubgraph  = regexp.MustCompile(`^    subgraph cluster[0-9]+ \[(.*)\]$`)
+)
+
+func parseFlowChart(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	states := make(map[string]string)
+
+	inCluster := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if inCluster && line == "    end" {
+			inCluster = false
+
+			continue
+		} else if inCluster {
+			line = strings.TrimPrefix(line, "    ")
+		}
+
+		if match := flowChartSubgraph.FindStringSubmatch(line); match != nil && !inCluster {
+			checkLabel(t, "flow chart", match[1], " -_.,/!?@'+=^$", entityPattern)
+			inCluster = true
+		} else if match := flowChartState.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "flow chart", match[2], " -_.,/!?@'+=^$", entityPattern)
+			states[match[1]] = decodeEntities(match[2])
+		} else if match := flowChartEdge.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "flow chart", match[2], " -_.,/!?@'+=^$", entityPattern)
+			edges = append(edges, visualizedEdge{states[match[1]], decodeEntities(match[2]), states[match[3]]})
+		} else if line != "graph LR" && line != "" && !flowChartHighlight.MatchString(line) {
+			t.Errorf("unexpected flow chart line %q", line)
+		}
+	}
+
+	return edges
+}
+
+var (
+	plantUMLState   = regexp.MustCompile(`^    state "([^"]*)" as (id[0-9]+)( #00AA00)?$`)
+	plantUMLEdge    = regexp.MustCompile(`^    (id[0-9]+) --> (id[0-9]+) : (.*)$`)
+	plantUMLMarkers = regexp.MustCompile(`^    (\[\*\] --> id[0-9]+|id[0-9]+ --> \[\*\])$`)
+	plantUMLCluster = regexp.MustCompile(`^    state "([^"]*)" as cluster[0-9]+ \{$`)
+)
+
+func parsePlantUML(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	states := make(map[string]string)
+
+	inCluster := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if inCluster && line == "    }" {
+			inCluster = false
+
+			continue
+		} else if inCluster {
+			line = strings.TrimPrefix(line, "    ")
+		}
+
+		if match := plantUMLCluster.FindStringSubmatch(line); match != nil && !inCluster {
+			checkLabel(t, "plantuml", match[1], " -_.,/!?@+=^$:;()[]{}%|", htmlEntityPattern)
+			inCluster = true
+		} else if match := plantUMLState.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "plantuml", match[1], " -_.,/!?@+=^$:;()[]{}%|", htmlEntityPattern)
+			states[match[2]] = decodeHTMLEntities(match[1])
+		} else if match := plantUMLEdge.FindStringSubmatch(line); match != nil {
+			checkLabel(t, "plantuml", match[3], " -_.,/!?@+=^$:;()[]{}%|", htmlEntityPattern)
+			edges = append(edges, visualizedEdge{states[match[1]], decodeHTMLEntities(match[3]), states[match[2]]})
+		} else if line != "@startuml" && line != "@enduml" && line != "" && !plantUMLMarkers.MatchString(line) {
+			t.Errorf("unexpected plantuml line %q", line)
+		}
+	}
+
+	return edges
+}
+
+var (
+	d2String = `"((?:[^"\\\x00-\x1f]|\\.|\\u[0-9a-f]{4})*)"`
+	d2State  = regexp.MustCompile(`^(id[0-9]+): ` + d2String + `( \{style\.fill: "#00AA00"\})?$`)
+	d2Edge   = regexp.MustCompile(`^(?:cluster[0-9]+\.)?(id[0-9]+) -> (?:cluster[0-9]+\.)?(id[0-9]+): ` + d2String + `$`)
+	d2Group  = regexp.MustCompile(`^cluster[0-9]+: ` + d2String + ` \{$`)
+	d2Escape = regexp.MustCompile(`\\(u[0-9a-f]{4}|.)`)
+)
+
+func decodeD2(s string) string {
+	return d2Escape.ReplaceAllStringFunc(s, func(escape string) string {
+		switch escape[1] {
+		case 'n':
+			return "\n"
+		case 'r':
+			return "\r"
+		case 't':
+			return "\t"
+		case 'u':
+			code, _ := strconv.ParseInt(escape[2:], 16, 32)
+
+			return string(rune(code))
+		default:
+			return escape[1:]
+		}
+	})
+}
+
+func parseD2(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	states := make(map[string]string)
+
+	inCluster := false
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		if inCluster && line == "}" {
+			inCluster = false
+
+			continue
+		} else if inCluster {
+			line = strings.TrimPrefix(line, "  ")
+		}
+
+		if !inCluster && d2Group.MatchString(line) {
+			inCluster = true
+		} else if match := d2State.FindStringSubmatch(line); match != nil {
+			states[match[1]] = decodeD2(match[2])
+		} else if match := d2Edge.FindStringSubmatch(line); match != nil {
+			if strings.Contains(regexp.MustCompile(`\\.`).ReplaceAllString(match[3], ""), "$") {
+				t.Errorf("d2 string %q contains an unescaped substitution", match[3])
+			}
+			edges = append(edges, visualizedEdge{states[match[1]], decodeD2(match[3]), states[match[2]]})
+		} else if line != "direction: right" && line != "" {
+			t.Errorf("unexpected d2 line %q", line)
+		}
+	}
+
+	return edges
+}
+
+func FuzzVisualizersEscaping(f *testing.F) {
+	f.Add("closed", "open", "open")
+	f.Add("on hold", "off: now", "part-close")
+	f.Add(`a"b`, `c\d`, "x\ny")
+	f.Add("state", "id0", "end")
+	f.Add("日本", "état", "→ next")
+	f.Add("#35;", "&amp;", "${x}")
+	f.Add(" padded ", "[x]", "|y|")
+	f.Add("pay.x", "cluster0", "go")
+	f.Add("a.id1", "a.b", "cluster1")
+
+	f.Fuzz(func(t *testing.T, source, destination, name string) {
+		for _, s := range []string{source, destination, name} {
+			if s == "" || !utf8.ValidString(s) {
+				t.Skip()
+			}
+		}
+
+		machine := NewMachine([]TransitionDesc{{Name: name, Sources: []string{source}, Destination: destination}}, map[string]Callback{})
+		instance := machine.NewInstance(source)
+
+		wanted := visualizedEdge{source, name, destination}
+
+		parsers := map[VisualizeType]func(*testing.T, string) []visualizedEdge{
+			GRAPHVIZ:            parseGraphviz,
+			MermaidStateDiagram: parseStateDiagram,
+			MermaidFlowChart:    parseFlowChart,
+			PLANTUML:            parsePlantUML,
+			D2:                  parseD2,
+			SVG:                 parseSVG,
+		}
+
+		for visualizeType, parse := range parsers {
+			for _, options := range [][]VisualizeOption{nil, {WithClusters(".")}} {
+				output, err := VisualizeWithType(machine, instance, visualizeType, options...)
+				if err != nil {
+					t.Fatalf("got error for visualizing with type %s: %s", visualizeType, err)
+				}
+
+				wanted := wanted
+				if visualizeType == SVG {
+					// XML can not represent every character
+					wanted = visualizedEdge{xmlSafe(source), xmlSafe(name), xmlSafe(destination)}
+				}
+
+				edges := parse(t, output)
+				if len(edges) != 1 || edges[0] != wanted {
+					t.Errorf("%s output does not read back as %v, got %v in\n%s", visualizeType, wanted, edges, output)
+				}
+			}
+		}
+
+		for _, visualizeType := range []VisualizeType{ASCII, UNICODE} {
+			output, _ := VisualizeWithType(machine, instance, visualizeType)
+			lines := strings.Split(output, "\n")
+			// the state boxes must keep their shape, three lines of equal width
+			if utf8.RuneCountInString(lines[0]) != utf8.RuneCountInString(lines[1]) || strings.Count(output, "\n") < 5 {
+				t.Errorf("%s output is broken:\n%s", visualizeType, output)
+			}
+		}
+	})
+}
+
+func TestMermaidStateDiagramEscaping(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "hold: now", Sources: []string{"open"}, Destination: "on hold"},
+		},
+		map[string]Callback{},
+	)
+
+	got, _ := VisualizeForMermaidWithGraphType(machineUnderTest, machineUnderTest.NewInstance("open"), StateDiagram)
+	wanted := fmt.Sprint(
+		"stateDiagram-v2\n",
+		"    state \"on hold\" as id0\n",
+		"    [*] --> open\n",
+		"    open --> id0: hold#58; now\n",
+	)
+
+	if got != wanted {
+		t.Errorf("build mermaid graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+}
@@ -3,56 +3,114 @@ package pkg
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 // Visualize outputs a visualization of a FSM in Graphviz format.
-func Visualize(machine *Machine, fsm *Instance) string {
+func Visualize(machine *Machine, fsm *Instance, options ...VisualizeOption) string {
 	var buf bytes.Buffer
 
-	// we sort the key alphabetically to have a reproducible graph output
-	sortedEKeys := getSortedTransitionKeys(machine.transitions)
-	sortedStateKeys, _ := getSortedStates(machine.transitions)
+	d := newDiagram(machine, fsm, options)
 
-	writeHeaderLine(&buf)
-	writeTransitions(&buf, fsm.current, sortedEKeys, machine.transitions)
-	writeStates(&buf, sortedStateKeys)
+	writeHeaderLine(&buf, d.config.direction)
+	writeTransitions(&buf, d.current, d.edges)
+	writeStates(&buf, d, d.unclusteredStates(), "    ")
+	writeClusters(&buf, d)
+	writeLegend(&buf, d.legend)
 	writeFooter(&buf)
 
 	return buf.String()
 }
 
-func writeHeaderLine(buf *bytes.Buffer) {
+func writeHeaderLine(buf *bytes.Buffer, direction Direction) {
 	buf.WriteString(`digraph fsm {`)
 	buf.WriteString("\n")
+
+	if direction != "" {
+		buf.WriteString(fmt.Sprintf(`    rankdir = %s;`, direction))
+		buf.WriteString("\n")
+	}
 }
 
-func writeTransitions(buf *bytes.Buffer, current string, sortedEKeys []transitionKey, transitions map[transitionKey]string) {
+func writeTransitions(buf *bytes.Buffer, current string, edges []diagramEdge) {
 	// make sure the current state is at top
-	for _, k := range sortedEKeys {
-		if k.source == current {
-			v := transitions[k]
-			buf.WriteString(fmt.Sprintf(`    "%s" -> "%s" [ label = "%s" ];`, k.source, v, k.name))
-			buf.WriteString("\n")
+	for _, edge := range edges {
+		if edge.source == current {
+			writeTransition(buf, edge)
 		}
 	}
-	for _, k := range sortedEKeys {
-		if k.source != current {
-			v := transitions[k]
-			buf.WriteString(fmt.Sprintf(`    "%s" -> "%s" [ label = "%s" ];`, k.source, v, k.name))
-			buf.WriteString("\n")
+	for _, edge := range edges {
+		if edge.source != current {
+			writeTransition(buf, edge)
 		}
 	}
 
 	buf.WriteString("\n")
 }
 
-func writeStates(buf *bytes.Buffer, sortedStateKeys []string) {
-	for _, k := range sortedStateKeys {
-		buf.WriteString(fmt.Sprintf(`    "%s";`, k))
+func writeTransition(buf *bytes.Buffer, edge diagramEdge) {
+	attributes := []string{fmt.Sprintf(`label = "%s"`, escapeGraphviz(edge.label))}
+	if edge.color != "" {
+		attributes = append(attributes, fmt.Sprintf(`color = "%s"`, escapeGraphviz(edge.color)))
+	}
+	if edge.width != 0 {
+		attributes = append(attributes, fmt.Sprintf(`penwidth = %d`, edge.width))
+	}
+
+	buf.WriteString(fmt.Sprintf(`    "%s" -> "%s" [ %s ];`, escapeGraphviz(edge.source), escapeGraphviz(edge.destination), strings.Join(attributes, ", ")))
+	buf.WriteString("\n")
+}
+
+func writeStates(buf *bytes.Buffer, d *diagram, states []diagramState, indent string) {
+	for _, state := range states {
+		var attributes []string
+		if state.label != state.name {
+			attributes = append(attributes, fmt.Sprintf(`label = "%s"`, escapeGraphviz(state.label)))
+		}
+		if fill := d.fillColor(state, ""); fill != "" {
+			attributes = append(attributes, `style = "filled"`, fmt.Sprintf(`fillcolor = "%s"`, escapeGraphviz(fill)))
+		}
+		if state.style.BorderColor != "" {
+			attributes = append(attributes, fmt.Sprintf(`color = "%s"`, escapeGraphviz(state.style.BorderColor)))
+		}
+		if state.style.TextColor != "" {
+			attributes = append(attributes, fmt.Sprintf(`fontcolor = "%s"`, escapeGraphviz(state.style.TextColor)))
+		}
+
+		if len(attributes) == 0 {
+			buf.WriteString(fmt.Sprintf(`%s"%s";`, indent, escapeGraphviz(state.name)))
+		} else {
+			buf.WriteString(fmt.Sprintf(`%s"%s" [ %s ];`, indent, escapeGraphviz(state.name), strings.Join(attributes, ", ")))
+		}
 		buf.WriteString("\n")
 	}
 }
 
+func writeClusters(buf *bytes.Buffer, d *diagram) {
+	for _, cluster := range d.clusters {
+		buf.WriteString(fmt.Sprintf(`    subgraph "%s" {`, cluster.id))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf(`        label = "%s";`, escapeGraphviz(cluster.name)))
+		buf.WriteString("\n")
+		writeStates(buf, d, cluster.states, "        ")
+		buf.WriteString("    }\n")
+	}
+}
+
+func writeLegend(buf *bytes.Buffer, legend []string) {
+	if len(legend) == 0 {
+		return
+	}
+
+	var label string
+	for _, line := range legend {
+		label += escapeGraphviz(line) + `\l`
+	}
+
+	buf.WriteString(fmt.Sprintf(`    graph [ label = "%s", labelloc = "b", labeljust = "l" ];`, label))
+	buf.WriteString("\n")
+}
+
 func writeFooter(buf *bytes.Buffer) {
 	buf.WriteString(fmt.Sprintln("}"))
 }
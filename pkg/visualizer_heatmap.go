@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	// heatmapColdColor the color of the least used states and transitions of a heatmap
+	heatmapColdColor = "#FDD49E"
+	// heatmapHotColor the color of the most used states and transitions of a heatmap
+	heatmapHotColor = "#D7301F"
+	// heatmapUnusedColor the color of the transitions of a heatmap that were never taken
+	heatmapUnusedColor = "#BBBBBB"
+	// heatmapMaxWidth the width of the most used transitions of a heatmap
+	heatmapMaxWidth = 5
+)
+
+// WithHeatmap weights the visualization with the usage of the machine: the
+// transitions and states show their counts, the transitions get thicker and
+// hotter with their frequency and the states, if given, get hotter with their
+// occupancy. Graphviz, Mermaid and PlantUML outputs include a legend.
+func WithHeatmap(heatmap Heatmap) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.heatmap = &heatmap
+	}
+}
+
+// applyHeatmap styles the states and edges with the configured heatmap.
+func (d *diagram) applyHeatmap() {
+	heatmap := d.config.heatmap
+	if heatmap == nil {
+		return
+	}
+
+	edgeCounts := make([]int, 0, len(d.edges))
+	for _, edge := range d.edges {
+		edgeCounts = append(edgeCounts, heatmap.Transitions[TransitionKey{edge.name, edge.source}])
+	}
+
+	stateCounts := make([]int, 0, len(d.states))
+	for _, state := range d.states {
+		stateCounts = append(stateCounts, heatmap.States[state.name])
+	}
+
+	unusedColor := heatmap.UnusedColor
+	if unusedColor == "" {
+		unusedColor = heatmapUnusedColor
+	}
+
+	edgeMin, edgeMax, edgeUnused := heatmapRange(edgeCounts)
+	stateMin, stateMax, _ := heatmapRange(stateCounts)
+
+	for i, edge := range d.edges {
+		count := edgeCounts[i]
+		d.edges[i].label = fmt.Sprintf("%s (%d)", edge.label, count)
+
+		if count == 0 {
+			d.edges[i].color, d.edges[i].width = unusedColor, 1
+			continue
+		}
+
+		heat := heatmapHeat(count, edgeMin, edgeMax)
+		d.edges[i].color = heatmapColor(heat)
+		d.edges[i].width = 1 + int(math.Round(heat*(heatmapMaxWidth-1)))
+	}
+
+	if heatmap.States != nil {
+		for i, state := range d.states {
+			count := stateCounts[i]
+			d.states[i].label = fmt.Sprintf("%s (%d)", state.label, count)
+
+			if count > 0 && state.style.FillColor == "" {
+				d.states[i].style.FillColor = heatmapColor(heatmapHeat(count, stateMin, stateMax))
+			}
+		}
+	}
+
+	d.legend = []string{"heatmap"}
+	if edgeMax > 0 {
+		d.legend = append(d.legend, fmt.Sprintf("transitions: %d (%s, width 1) to %d (%s, width %d)",
+			edgeMin, heatmapColdColor, edgeMax, heatmapHotColor, heatmapMaxWidth))
+	}
+	if edgeUnused {
+		d.legend = append(d.legend, fmt.Sprintf("never taken: %s", unusedColor))
+	}
+	if stateMax > 0 {
+		d.legend = append(d.legend, fmt.Sprintf("occupancy: %d (%s) to %d (%s)",
+			stateMin, heatmapColdColor, stateMax, heatmapHotColor))
+	}
+}
+
+// heatmapRange returns the lowest and highest positive counts and whether any
+// count is zero.
+func heatmapRange(counts []int) (lowest, highest int, zero bool) {
+	for _, count := range counts {
+		switch {
+		case count <= 0:
+			zero = true
+		case highest == 0:
+			lowest, highest = count, count
+		case count < lowest:
+			lowest = count
+		case count > highest:
+			highest = count
+		}
+	}
+
+	return lowest, highest, zero
+}
+
+// heatmapHeat returns the position of count between lowest and highest, from 0 to 1.
+func heatmapHeat(count, lowest, highest int) float64 {
+	if highest == lowest {
+		return 1
+	}
+
+	return float64(count-lowest) / float64(highest-lowest)
+}
+
+// heatmapColor interpolates the color of heat between the cold and hot colors.
+func heatmapColor(heat float64) string {
+	var cold, hot [3]int
+	_, _ = fmt.Sscanf(heatmapColdColor, "#%02X%02X%02X", &cold[0], &cold[1], &cold[2])
+	_, _ = fmt.Sscanf(heatmapHotColor, "#%02X%02X%02X", &hot[0], &hot[1], &hot[2])
+
+	var rgb [3]int
+	for i := range rgb {
+		rgb[i] = cold[i] + int(math.Round(heat*float64(hot[i]-cold[i])))
+	}
+
+	return fmt.Sprintf("#%02X%02X%02X", rgb[0], rgb[1], rgb[2])
+}
@@ -3,6 +3,7 @@ package pkg
 import (
 	"bytes"
 	"fmt"
+	"strings"
 )
 
 const highlightingColor = "#00AA00"
@@ -18,72 +19,196 @@ const (
 )
 
 // VisualizeForMermaidWithGraphType outputs a visualization of a FSM in Mermaid format as specified by the graphType.
-func VisualizeForMermaidWithGraphType(machine *Machine, fsm *Instance, graphType MermaidDiagramType) (string, error) {
+func VisualizeForMermaidWithGraphType(machine *Machine, fsm *Instance, graphType MermaidDiagramType, options ...VisualizeOption) (string, error) {
 	switch graphType {
 	case FlowChart:
-		return visualizeForMermaidAsFlowChart(machine, fsm), nil
+		return visualizeForMermaidAsFlowChart(newDiagram(machine, fsm, options)), nil
 	case StateDiagram:
-		return visualizeForMermaidAsStateDiagram(machine, fsm), nil
+		return visualizeForMermaidAsStateDiagram(newDiagram(machine, fsm, options)), nil
 	default:
 		return "", fmt.Errorf("unknown MermaidDiagramType: %s", graphType)
 	}
 }
 
-func visualizeForMermaidAsStateDiagram(machine *Machine, fsm *Instance) string {
+func visualizeForMermaidAsStateDiagram(d *diagram) string {
 	var buf bytes.Buffer
 
-	sortedTransitionKeys := getSortedTransitionKeys(machine.transitions)
+	statesToIDMap := d.statesToIDMap
+	if _, ok := statesToIDMap[d.current]; !ok {
+		statesToIDMap[d.current] = fmt.Sprintf("id%d", len(statesToIDMap))
+		d.states = append(d.states, diagramState{name: d.current, id: statesToIDMap[d.current], label: d.current, current: true})
+	}
 
+	writeMermaidTitle(&buf, d.legend)
 	buf.WriteString("stateDiagram-v2\n")
-	buf.WriteString(fmt.Sprintln(`    [*] -->`, fsm.current))
+	if d.config.direction != "" {
+		buf.WriteString(fmt.Sprintf("    direction %s\n", d.config.direction))
+	}
+	writeStateDiagramStates(&buf, d.unclusteredStates(), statesToIDMap, "    ")
+	writeStateDiagramClusters(&buf, d)
+	buf.WriteString(fmt.Sprintln(`    [*] -->`, stateDiagramID(d.current, statesToIDMap)))
 
-	for _, k := range sortedTransitionKeys {
-		v := machine.transitions[k]
-		buf.WriteString(fmt.Sprintf(`    %s --> %s: %s`, k.source, v, k.name))
+	for _, edge := range d.edges {
+		buf.WriteString(fmt.Sprintf(`    %s --> %s: %s`, stateDiagramID(edge.source, statesToIDMap), stateDiagramID(edge.destination, statesToIDMap), escapeMermaid(edge.label)))
 		buf.WriteString("\n")
 	}
 
+	writeStateDiagramStyles(&buf, d)
+
 	return buf.String()
 }
 
+// writeStateDiagramStates declares the states that can not be used as IDs in a
+// state diagram, or have a label other than their name, under their generated ID.
+func writeStateDiagramStates(buf *bytes.Buffer, states []diagramState, statesToIDMap map[string]string, indent string) {
+	for _, state := range states {
+		if !isMermaidStateID(state.name) || state.label != state.name {
+			buf.WriteString(fmt.Sprintf(`%sstate "%s" as %s`, indent, escapeMermaid(state.label), stateDiagramID(state.name, statesToIDMap)))
+			buf.WriteString("\n")
+		}
+	}
+}
+
+// writeStateDiagramClusters writes the clusters as composite states.
+func writeStateDiagramClusters(buf *bytes.Buffer, d *diagram) {
+	for _, cluster := range d.clusters {
+		buf.WriteString(fmt.Sprintf(`    state "%s" as %s`, escapeMermaid(cluster.name), cluster.id))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf(`    state %s {`, cluster.id))
+		buf.WriteString("\n")
+
+		for _, state := range cluster.states {
+			if isMermaidStateID(state.name) && state.label == state.name {
+				buf.WriteString(fmt.Sprintf(`        %s`, state.name))
+				buf.WriteString("\n")
+			}
+		}
+		writeStateDiagramStates(buf, cluster.states, d.statesToIDMap, "        ")
+
+		buf.WriteString("    }\n")
+	}
+}
+
+// writeStateDiagramStyles styles the states with classes, state diagrams not
+// supporting the style of individual states.
+func writeStateDiagramStyles(buf *bytes.Buffer, d *diagram) {
+	for i, state := range d.states {
+		style := mermaidStyle(d.fillColor(state, ""), state.style)
+		if style == "" {
+			continue
+		}
+
+		buf.WriteString(fmt.Sprintf(`    classDef style%d %s`, i, style))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf(`    class %s style%d`, stateDiagramID(state.name, d.statesToIDMap), i))
+		buf.WriteString("\n")
+	}
+}
+
+// stateDiagramID returns the ID of state in a state diagram, the state itself
+// if it can be used as an ID.
+func stateDiagramID(state string, statesToIDMap map[string]string) string {
+	if isMermaidStateID(state) {
+		return state
+	}
+
+	return statesToIDMap[state]
+}
+
+// mermaidStyle returns the Mermaid style properties of a state.
+func mermaidStyle(fill string, style StateStyle) string {
+	var properties []string
+	if fill != "" {
+		properties = append(properties, "fill:"+fill)
+	}
+	if style.BorderColor != "" {
+		properties = append(properties, "stroke:"+style.BorderColor)
+	}
+	if style.TextColor != "" {
+		properties = append(properties, "color:"+style.TextColor)
+	}
+
+	return strings.Join(properties, ",")
+}
+
+// writeMermaidTitle writes the legend of the diagram as its title in the front matter.
+func writeMermaidTitle(buf *bytes.Buffer, legend []string) {
+	if len(legend) == 0 {
+		return
+	}
+
+	buf.WriteString("---\n")
+	buf.WriteString(fmt.Sprintf("title: \"%s\"\n", strings.Join(legend, " | ")))
+	buf.WriteString("---\n")
+}
+
 // visualizeForMermaidAsFlowChart outputs a visualization of a FSM in Mermaid format (including highlighting of current state).
-func visualizeForMermaidAsFlowChart(machine *Machine, fsm *Instance) string {
+func visualizeForMermaidAsFlowChart(d *diagram) string {
 	var buf bytes.Buffer
 
-	sortedTransitionKeys := getSortedTransitionKeys(machine.transitions)
-	sortedStates, statesToIDMap := getSortedStates(machine.transitions)
-
-	writeFlowChartGraphType(&buf)
-	writeFlowChartStates(&buf, sortedStates, statesToIDMap)
-	writeFlowChartTransitions(&buf, machine.transitions, sortedTransitionKeys, statesToIDMap)
-	writeFlowChartHighlightCurrent(&buf, fsm.current, statesToIDMap)
+	writeMermaidTitle(&buf, d.legend)
+	writeFlowChartGraphType(&buf, d.config.direction)
+	writeFlowChartStates(&buf, d.unclusteredStates(), "    ")
+	writeFlowChartClusters(&buf, d)
+	buf.WriteString("\n")
+	writeFlowChartTransitions(&buf, d.edges, d.statesToIDMap)
+	writeFlowChartStyles(&buf, d)
 
 	return buf.String()
 }
 
-func writeFlowChartGraphType(buf *bytes.Buffer) {
-	buf.WriteString("graph LR\n")
+func writeFlowChartGraphType(buf *bytes.Buffer, direction Direction) {
+	if direction == "" {
+		direction = LeftToRight
+	}
+
+	buf.WriteString(fmt.Sprintf("graph %s\n", direction))
 }
 
-func writeFlowChartStates(buf *bytes.Buffer, sortedStates []string, statesToIDMap map[string]string) {
-	for _, state := range sortedStates {
-		buf.WriteString(fmt.Sprintf(`    %s[%s]`, statesToIDMap[state], state))
+func writeFlowChartStates(buf *bytes.Buffer, states []diagramState, indent string) {
+	for _, state := range states {
+		buf.WriteString(fmt.Sprintf(`%s%s[%s]`, indent, state.id, escapeMermaid(state.label)))
 		buf.WriteString("\n")
 	}
+}
 
-	buf.WriteString("\n")
+func writeFlowChartClusters(buf *bytes.Buffer, d *diagram) {
+	for _, cluster := range d.clusters {
+		buf.WriteString(fmt.Sprintf(`    subgraph %s [%s]`, cluster.id, escapeMermaid(cluster.name)))
+		buf.WriteString("\n")
+		writeFlowChartStates(buf, cluster.states, "        ")
+		buf.WriteString("    end\n")
+	}
 }
 
-func writeFlowChartTransitions(buf *bytes.Buffer, transitions map[transitionKey]string, sortedTransitionKeys []transitionKey, statesToIDMap map[string]string) {
-	for _, transition := range sortedTransitionKeys {
-		target := transitions[transition]
-		buf.WriteString(fmt.Sprintf(`    %s --> |%s| %s`, statesToIDMap[transition.source], transition.name, statesToIDMap[target]))
+func writeFlowChartTransitions(buf *bytes.Buffer, edges []diagramEdge, statesToIDMap map[string]string) {
+	for _, edge := range edges {
+		buf.WriteString(fmt.Sprintf(`    %s --> |%s| %s`, statesToIDMap[edge.source], escapeMermaid(edge.label), statesToIDMap[edge.destination]))
 		buf.WriteString("\n")
 	}
 	buf.WriteString("\n")
 }
 
-func writeFlowChartHighlightCurrent(buf *bytes.Buffer, current string, statesToIDMap map[string]string) {
-	buf.WriteString(fmt.Sprintf(`    style %s fill:%s`, statesToIDMap[current], highlightingColor))
-	buf.WriteString("\n")
+func writeFlowChartStyles(buf *bytes.Buffer, d *diagram) {
+	for _, state := range d.states {
+		if style := mermaidStyle(d.fillColor(state, highlightingColor), state.style); style != "" {
+			buf.WriteString(fmt.Sprintf(`    style %s %s`, state.id, style))
+			buf.WriteString("\n")
+		}
+	}
+
+	for i, edge := range d.edges {
+		if edge.color != "" || edge.width != 0 {
+			var properties []string
+			if edge.color != "" {
+				properties = append(properties, "stroke:"+edge.color)
+			}
+			if edge.width != 0 {
+				properties = append(properties, fmt.Sprintf("stroke-width:%dpx", edge.width))
+			}
+
+			buf.WriteString(fmt.Sprintf(`    linkStyle %d %s`, i, strings.Join(properties, ",")))
+			buf.WriteString("\n")
+		}
+	}
 }
@@ -0,0 +1,370 @@
+package pkg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Direction the layout direction of a visualization
+type Direction string
+
+const (
+	// TopToBottom lays out the states from top to bottom
+	TopToBottom Direction = "TB"
+	// LeftToRight lays out the states from left to right
+	LeftToRight Direction = "LR"
+	// BottomToTop lays out the states from bottom to top
+	BottomToTop Direction = "BT"
+	// RightToLeft lays out the states from right to left
+	RightToLeft Direction = "RL"
+)
+
+const (
+	// defaultAvailableColor the color of the transitions available in the current state
+	defaultAvailableColor = "#1E90FF"
+	// defaultPathColor the color of the transitions of a highlighted path
+	defaultPathColor = "#FF8C00"
+	// defaultHistoryColor the color of the transitions taken in the history of an instance
+	defaultHistoryColor = "#8A2BE2"
+	// highlightedEdgeWidth the width of the highlighted transitions
+	highlightedEdgeWidth = 2
+)
+
+// StateStyle the style of a state in a visualization, empty fields keeping the format's default
+type StateStyle struct {
+	// FillColor the background color of the state
+	FillColor string
+	// BorderColor the color of the state's border
+	BorderColor string
+	// TextColor the color of the state's name
+	TextColor string
+}
+
+// VisualizeOption configures a visualization.
+//
+// Options are applied by all the formats supporting them: edge colors are not
+// available in Mermaid state diagrams, and the text formats ignore colors,
+// clusters and direction, drawing highlighted transitions with a bold arrow.
+type VisualizeOption func(*visualizeConfig)
+
+// visualizeConfig holds the options of a visualization.
+type visualizeConfig struct {
+	direction      Direction
+	currentColor   string
+	availableColor string
+	pathColor      string
+	stateStyles    map[string]StateStyle
+	clusterBy      string
+	hideSelfLoops  bool
+	showAvailable  bool
+	path           []string
+	history        []HistoryStep
+	historyColor   string
+	heatmap        *Heatmap
+}
+
+// WithDirection sets the layout direction. PlantUML only supports TopToBottom
+// and LeftToRight.
+func WithDirection(direction Direction) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.direction = direction
+	}
+}
+
+// WithCurrentColor sets the fill color of the current state, which is also
+// highlighted in the formats that do not highlight it by default.
+func WithCurrentColor(color string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.currentColor = color
+	}
+}
+
+// WithStateStyle sets the style of the given state, taking precedence over the
+// highlighting of the current state.
+func WithStateStyle(state string, style StateStyle) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.stateStyles[state] = style
+	}
+}
+
+// WithClusters groups the states by the prefix of their name before separator,
+// so states like payment.pending and payment.paid are drawn together.
+func WithClusters(separator string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.clusterBy = separator
+	}
+}
+
+// WithoutSelfLoops hides the transitions leading back to their source state.
+func WithoutSelfLoops() VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.hideSelfLoops = true
+	}
+}
+
+// WithAvailableTransitions highlights the transitions available in the current state.
+func WithAvailableTransitions() VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.showAvailable = true
+	}
+}
+
+// WithAvailableColor sets the color of the transitions highlighted by WithAvailableTransitions.
+func WithAvailableColor(color string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.availableColor = color
+	}
+}
+
+// WithPath highlights the transitions taken by the given events starting from
+// the current state, up to the first event that can not occur.
+func WithPath(events ...string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.path = events
+	}
+}
+
+// WithPathColor sets the color of the transitions highlighted by WithPath.
+func WithPathColor(color string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.pathColor = color
+	}
+}
+
+// WithHistory overlays the transitions an instance took, in order, on the
+// visualization: the traversed transitions are prefixed with the numbers of the steps they
+// were taken at, the states show how many times they were visited and the
+// current state is highlighted in every format.
+func WithHistory(history []HistoryStep) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.history = history
+	}
+}
+
+// WithHistoryColor sets the color of the transitions highlighted by WithHistory.
+func WithHistoryColor(color string) VisualizeOption {
+	return func(config *visualizeConfig) {
+		config.historyColor = color
+	}
+}
+
+// diagram is the format independent description of a visualization, built
+// from a machine, an instance and the options.
+type diagram struct {
+	machine *Machine
+	current string
+	config  visualizeConfig
+
+	// states and edges are sorted to have a reproducible graph output.
+	states   []diagramState
+	edges    []diagramEdge
+	clusters []diagramCluster
+
+	// legend are the lines explaining the styling of the diagram, if any.
+	legend []string
+
+	// statesToIDMap maps the states to their generated ID.
+	statesToIDMap map[string]string
+}
+
+// diagramState is a state of a diagram.
+type diagramState struct {
+	name    string
+	id      string
+	label   string
+	cluster string
+	current bool
+	style   StateStyle
+}
+
+// diagramEdge is a transition of a diagram.
+type diagramEdge struct {
+	name        string
+	source      string
+	destination string
+	label       string
+	color       string
+	width       int
+}
+
+// diagramCluster is a group of states of a diagram.
+type diagramCluster struct {
+	name   string
+	id     string
+	states []diagramState
+}
+
+// newDiagram builds the diagram of the machine with fsm in its current state.
+func newDiagram(machine *Machine, fsm *Instance, options []VisualizeOption) *diagram {
+	d := &diagram{
+		machine: machine,
+		current: fsm.Current(),
+		config: visualizeConfig{
+			availableColor: defaultAvailableColor,
+			pathColor:      defaultPathColor,
+			historyColor:   defaultHistoryColor,
+			stateStyles:    make(map[string]StateStyle),
+		},
+	}
+
+	for _, option := range options {
+		option(&d.config)
+	}
+
+	sortedStates, statesToIDMap := getSortedStates(machine.transitions)
+	d.statesToIDMap = statesToIDMap
+
+	for _, state := range sortedStates {
+		d.states = append(d.states, diagramState{
+			name:    state,
+			id:      statesToIDMap[state],
+			label:   state,
+			cluster: d.clusterOf(state),
+			current: state == d.current,
+			style:   d.config.stateStyles[state],
+		})
+	}
+
+	pathKeys := d.pathKeys()
+
+	for _, k := range getSortedTransitionKeys(machine.transitions) {
+		destination := machine.transitions[k]
+		if d.config.hideSelfLoops && k.Src == destination {
+			continue
+		}
+
+		edge := diagramEdge{name: k.Event, source: k.Src, destination: destination, label: k.Event}
+
+		if pathKeys[k] {
+			edge.color, edge.width = d.config.pathColor, highlightedEdgeWidth
+		} else if d.config.showAvailable && k.Src == d.current {
+			edge.color, edge.width = d.config.availableColor, highlightedEdgeWidth
+		}
+
+		d.edges = append(d.edges, edge)
+	}
+
+	d.applyHistory()
+	d.applyHeatmap()
+	d.buildClusters()
+
+	return d
+}
+
+// applyHistory numbers the traversed transitions and counts the visits of the
+// states of the configured history.
+func (d *diagram) applyHistory() {
+	if len(d.config.history) == 0 {
+		return
+	}
+
+	if d.config.currentColor == "" {
+		d.config.currentColor = highlightingColor
+	}
+
+	visits := map[string]int{d.config.history[0].Src: 1}
+	steps := make(map[TransitionKey][]string)
+
+	for i, step := range d.config.history {
+		visits[step.Dst]++
+
+		k := TransitionKey{step.Event, step.Src}
+		steps[k] = append(steps[k], strconv.Itoa(i+1))
+	}
+
+	for i, state := range d.states {
+		if count := visits[state.name]; count > 0 {
+			d.states[i].label = fmt.Sprintf("%s (%d)", state.label, count)
+		}
+	}
+
+	for i, edge := range d.edges {
+		if numbers, ok := steps[TransitionKey{edge.name, edge.source}]; ok {
+			d.edges[i].label = strings.Join(numbers, ", ") + ". " + edge.label
+			d.edges[i].color, d.edges[i].width = d.config.historyColor, highlightedEdgeWidth
+		}
+	}
+}
+
+// pathKeys returns the transitions taken by the configured path.
+func (d *diagram) pathKeys() map[TransitionKey]bool {
+	keys := make(map[TransitionKey]bool)
+
+	state := d.current
+	for _, event := range d.config.path {
+		k := TransitionKey{event, state}
+
+		destination, ok := d.machine.transitions[k]
+		if !ok {
+			break
+		}
+
+		keys[k] = true
+		state = destination
+	}
+
+	return keys
+}
+
+// clusterOf returns the cluster of state, or "" if it is not clustered.
+func (d *diagram) clusterOf(state string) string {
+	if d.config.clusterBy == "" {
+		return ""
+	}
+
+	if i := strings.Index(state, d.config.clusterBy); i > 0 {
+		return state[:i]
+	}
+
+	return ""
+}
+
+// buildClusters groups the clustered states.
+func (d *diagram) buildClusters() {
+	byName := make(map[string][]diagramState)
+	for _, state := range d.states {
+		if state.cluster != "" {
+			byName[state.cluster] = append(byName[state.cluster], state)
+		}
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		d.clusters = append(d.clusters, diagramCluster{name, fmt.Sprintf("cluster%d", i), byName[name]})
+	}
+}
+
+// unclusteredStates returns the states outside of any cluster.
+func (d *diagram) unclusteredStates() []diagramState {
+	var states []diagramState
+
+	for _, state := range d.states {
+		if state.cluster == "" {
+			states = append(states, state)
+		}
+	}
+
+	return states
+}
+
+// fillColor returns the fill color of state, formatDefault being used for the
+// current state if the format highlights it without WithCurrentColor.
+func (d *diagram) fillColor(state diagramState, formatDefault string) string {
+	switch {
+	case state.style.FillColor != "":
+		return state.style.FillColor
+	case !state.current:
+		return ""
+	case d.config.currentColor != "":
+		return d.config.currentColor
+	default:
+		return formatDefault
+	}
+}
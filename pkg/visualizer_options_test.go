@@ -0,0 +1,144 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func newOptionsMachine() *Machine {
+	return NewMachine(
+		[]TransitionDesc{
+			{Name: "pay", Sources: []string{"ride.finished"}, Destination: "payment.pending"},
+			{Name: "confirm", Sources: []string{"payment.pending"}, Destination: "payment.paid"},
+			{Name: "retry", Sources: []string{"payment.pending"}, Destination: "payment.pending"},
+		},
+		map[string]Callback{},
+	)
+}
+
+func TestGraphvizOutputWithOptions(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("ride.finished")
+
+	got := Visualize(machineUnderTest, i,
+		WithDirection(LeftToRight),
+		WithCurrentColor("#00FF00"),
+		WithStateStyle("payment.paid", StateStyle{FillColor: "#CCCCCC", BorderColor: "#000000", TextColor: "#FFFFFF"}),
+		WithClusters("."),
+		WithoutSelfLoops(),
+		WithPath("pay", "confirm"),
+	)
+
+	wanted := `digraph fsm {
+    rankdir = LR;
+    "ride.finished" -> "payment.pending" [ label = "pay", color = "#FF8C00", penwidth = 2 ];
+    "payment.pending" -> "payment.paid" [ label = "confirm", color = "#FF8C00", penwidth = 2 ];
+
+    subgraph "cluster0" {
+        label = "payment";
+        "payment.paid" [ style = "filled", fillcolor = "#CCCCCC", color = "#000000", fontcolor = "#FFFFFF" ];
+        "payment.pending";
+    }
+    subgraph "cluster1" {
+        label = "ride";
+        "ride.finished" [ style = "filled", fillcolor = "#00FF00" ];
+    }
+}
+`
+	if got != wanted {
+		t.Errorf("build graphivz graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+}
+
+func TestVisualizeWithAvailableTransitions(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("payment.pending")
+
+	tests := []struct {
+		visualizeType VisualizeType
+		wanted        []string
+	}{
+		{GRAPHVIZ, []string{`"payment.pending" -> "payment.paid" [ label = "confirm", color = "#123456", penwidth = 2 ];`}},
+		{MermaidFlowChart, []string{"graph TB", "linkStyle 0 stroke:#123456,stroke-width:2px", "linkStyle 1 stroke:#123456,stroke-width:2px"}},
+		{PLANTUML, []string{"top to bottom direction", "id1 -[#123456,bold]-> id0 : confirm", "id1 -[#123456,bold]-> id1 : retry"}},
+		{D2, []string{"direction: down", `id1 -> id0: "confirm" {style.stroke: "#123456"; style.stroke-width: 2}`}},
+		{ASCII, []string{"|-- confirm ==> payment.paid", "`-- retry ==> payment.pending", "`-- pay --> payment.pending"}},
+	}
+
+	for _, test := range tests {
+		got, err := VisualizeWithType(machineUnderTest, i, test.visualizeType,
+			WithDirection(TopToBottom),
+			WithAvailableTransitions(),
+			WithAvailableColor("#123456"),
+		)
+		if err != nil {
+			t.Errorf("got error for visualizing with type %s: %s", test.visualizeType, err)
+		}
+
+		for _, line := range test.wanted {
+			if !strings.Contains(got, line) {
+				t.Errorf("expected %s output to contain %q, got\n%s", test.visualizeType, line, got)
+			}
+		}
+	}
+}
+
+func TestMermaidStateDiagramWithOptions(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("payment.pending")
+
+	got, err := VisualizeForMermaidWithGraphType(machineUnderTest, i, StateDiagram,
+		WithDirection(LeftToRight),
+		WithCurrentColor("#00FF00"),
+		WithClusters("."),
+	)
+	if err != nil {
+		t.Errorf("got error for visualizing with type MERMAID: %s", err)
+	}
+
+	wanted := `stateDiagram-v2
+    direction LR
+    state "payment" as cluster0
+    state cluster0 {
+        state "payment.paid" as id0
+        state "payment.pending" as id1
+    }
+    state "ride" as cluster1
+    state cluster1 {
+        state "ride.finished" as id2
+    }
+    [*] --> id1
+    id1 --> id0: confirm
+    id1 --> id1: retry
+    id2 --> id1: pay
+    classDef style1 fill:#00FF00
+    class id1 style1
+`
+	if got != wanted {
+		t.Errorf("build mermaid graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+}
+
+func TestD2ClustersWithOptions(t *testing.T) {
+	machineUnderTest := newOptionsMachine()
+	i := machineUnderTest.NewInstance("ride.finished")
+
+	got := VisualizeForD2(machineUnderTest, i, WithClusters("."), WithoutSelfLoops())
+
+	wanted := `direction: right
+
+cluster0: "payment" {
+  id0: "payment.paid"
+  id1: "payment.pending"
+}
+cluster1: "ride" {
+  id2: "ride.finished" {style.fill: "#00AA00"}
+}
+
+cluster0.id1 -> cluster0.id0: "confirm"
+cluster1.id2 -> cluster0.id1: "pay"
+`
+	if got != wanted {
+		t.Errorf("build d2 graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+	}
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// VisualizeForPlantUML outputs a visualization of a FSM in PlantUML state diagram format.
+func VisualizeForPlantUML(machine *Machine, fsm *Instance, options ...VisualizeOption) string {
+	var buf bytes.Buffer
+
+	d := newDiagram(machine, fsm, options)
+
+	buf.WriteString("@startuml\n")
+	writePlantUMLDirection(&buf, d.config.direction)
+	writePlantUMLStates(&buf, d, d.unclusteredStates(), "    ")
+	writePlantUMLClusters(&buf, d)
+	buf.WriteString("\n")
+	writePlantUMLTransitions(&buf, d)
+	writePlantUMLLegend(&buf, d.legend)
+	buf.WriteString("@enduml\n")
+
+	return buf.String()
+}
+
+func writePlantUMLDirection(buf *bytes.Buffer, direction Direction) {
+	switch direction {
+	case LeftToRight:
+		buf.WriteString("    left to right direction\n")
+	case TopToBottom:
+		buf.WriteString("    top to bottom direction\n")
+	}
+}
+
+func writePlantUMLStates(buf *bytes.Buffer, d *diagram, states []diagramState, indent string) {
+	for _, state := range states {
+		if style := plantUMLStyle(d.fillColor(state, highlightingColor), state.style); style != "" {
+			buf.WriteString(fmt.Sprintf(`%sstate "%s" as %s %s`, indent, escapePlantUML(state.label), state.id, style))
+		} else {
+			buf.WriteString(fmt.Sprintf(`%sstate "%s" as %s`, indent, escapePlantUML(state.label), state.id))
+		}
+		buf.WriteString("\n")
+	}
+}
+
+func writePlantUMLClusters(buf *bytes.Buffer, d *diagram) {
+	for _, cluster := range d.clusters {
+		buf.WriteString(fmt.Sprintf(`    state "%s" as %s {`, escapePlantUML(cluster.name), cluster.id))
+		buf.WriteString("\n")
+		writePlantUMLStates(buf, d, cluster.states, "        ")
+		buf.WriteString("    }\n")
+	}
+}
+
+func writePlantUMLTransitions(buf *bytes.Buffer, d *diagram) {
+	if id, ok := d.statesToIDMap[d.current]; ok {
+		buf.WriteString(fmt.Sprintf(`    [*] --> %s`, id))
+		buf.WriteString("\n")
+	}
+
+	for _, edge := range d.edges {
+		arrow := "-->"
+		if edge.color != "" {
+			arrow = fmt.Sprintf("-[%s,bold]->", edge.color)
+		}
+
+		buf.WriteString(fmt.Sprintf(`    %s %s %s : %s`, d.statesToIDMap[edge.source], arrow, d.statesToIDMap[edge.destination], escapePlantUML(edge.label)))
+		buf.WriteString("\n")
+	}
+
+	for _, state := range d.states {
+		if d.machine.isFinal(state.name) {
+			buf.WriteString(fmt.Sprintf(`    %s --> [*]`, state.id))
+			buf.WriteString("\n")
+		}
+	}
+}
+
+func writePlantUMLLegend(buf *bytes.Buffer, legend []string) {
+	if len(legend) == 0 {
+		return
+	}
+
+	buf.WriteString("    legend\n")
+	for _, line := range legend {
+		buf.WriteString(fmt.Sprintf("        %s\n", escapePlantUML(line)))
+	}
+	buf.WriteString("    endlegend\n")
+}
+
+// plantUMLStyle returns the PlantUML color specification of a state.
+func plantUMLStyle(fill string, style StateStyle) string {
+	var properties []string
+	if fill != "" {
+		properties = append(properties, fill)
+	}
+	if style.BorderColor != "" {
+		properties = append(properties, "line:"+strings.TrimPrefix(style.BorderColor, "#"))
+	}
+	if style.TextColor != "" {
+		properties = append(properties, "text:"+strings.TrimPrefix(style.TextColor, "#"))
+	}
+
+	if len(properties) == 0 {
+		return ""
+	}
+
+	if fill == "" {
+		return "#" + strings.Join(properties, ";")
+	}
+
+	return strings.Join(properties, ";")
+}
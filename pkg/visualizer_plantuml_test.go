@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestPlantUMLOutput(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+			{Name: "part-close", Sources: []string{"intermediate"}, Destination: "closed"},
+			{Name: "break", Sources: []string{"open"}, Destination: "broken"},
+		},
+		map[string]Callback{},
+	)
+
+	i := machineUnderTest.NewInstance("closed")
+
+	got, err := VisualizeWithType(machineUnderTest, i, PLANTUML)
+	if err != nil {
+		t.Errorf("got error for visualizing with type PLANTUML: %s", err)
+	}
+	wanted := `
+@startuml
+    state "broken" as id0
+    state "closed" as id1 #00AA00
+    state "intermediate" as id2
+    state "open" as id3
+
+    [*] --> id1
+    id1 --> id3 : open
+    id2 --> id1 : part-close
+    id3 --> id0 : break
+    id3 --> id1 : close
+    id0 --> [*]
+@enduml
+`
+	normalizedGot := strings.ReplaceAll(got, "\n", "")
+	normalizedWanted := strings.ReplaceAll(wanted, "\n", "")
+	if normalizedGot != normalizedWanted {
+		t.Errorf("build plantuml graph failed. \nwanted \n%s\nand got \n%s\n", wanted, got)
+		fmt.Println([]byte(normalizedGot))
+		fmt.Println([]byte(normalizedWanted))
+	}
+}
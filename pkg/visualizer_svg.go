@@ -0,0 +1,538 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// svgMargin the space around the graph
+	svgMargin = 20
+	// svgFontSize the size of the labels
+	svgFontSize = 14
+	// svgCharWidth the estimated width of a character of a label
+	svgCharWidth = 8
+	// svgStateHeight the height of the state boxes
+	svgStateHeight = 36
+	// svgStatePadding the horizontal space between a state label and its box
+	svgStatePadding = 24
+	// svgLayerGap the space between two layers of states
+	svgLayerGap = 90
+	// svgStateGap the space between two states of a layer
+	svgStateGap = 50
+	// svgParallelOffset the distance between transitions joining the same states
+	svgParallelOffset = 30
+	// svgLoopSize the extent of the self loops
+	svgLoopSize = 40
+	// svgEdgeColor the color of the transitions which are not highlighted
+	svgEdgeColor = "#333333"
+	// svgStateColor the fill color of the states which are not highlighted
+	svgStateColor = "#FFFFFF"
+)
+
+// svgPoint is a position in the SVG canvas.
+type svgPoint struct {
+	x, y float64
+}
+
+// svgNode is a state laid out on the canvas, centered on its position.
+type svgNode struct {
+	state         diagramState
+	layer, order  int
+	position      svgPoint
+	width, height float64
+}
+
+// svgLayout is the layered layout of a diagram.
+type svgLayout struct {
+	nodes         map[string]*svgNode
+	width, height float64
+}
+
+// VisualizeAsSVG outputs a visualization of a FSM as a self-contained SVG
+// image, laid out without external tools. Each state lists the callbacks that
+// may run when entering or leaving it in its tooltip. Clusters are not drawn.
+func VisualizeAsSVG(machine *Machine, fsm *Instance, options ...VisualizeOption) string {
+	var buf bytes.Buffer
+
+	writeSVG(&buf, newDiagram(machine, fsm, options))
+
+	return buf.String()
+}
+
+// VisualizeAsHTML outputs a visualization of a FSM as a standalone HTML page
+// embedding the SVG of VisualizeAsSVG.
+func VisualizeAsHTML(machine *Machine, fsm *Instance, options ...VisualizeOption) string {
+	var buf bytes.Buffer
+
+	title := "fsm"
+	if machine.name != "" {
+		title = machine.name
+	}
+
+	buf.WriteString("<!DOCTYPE html>\n")
+	buf.WriteString("<html>\n")
+	buf.WriteString("<head>\n")
+	buf.WriteString(`<meta charset="utf-8">` + "\n")
+	buf.WriteString(fmt.Sprintf("<title>%s</title>\n", escapeXML(title)))
+	buf.WriteString("<style>\n")
+	buf.WriteString("body { font-family: sans-serif; margin: 0; padding: 16px; }\n")
+	buf.WriteString(".state:hover rect { stroke-width: 3; }\n")
+	buf.WriteString(".transition:hover path { stroke-width: 3; }\n")
+	buf.WriteString("</style>\n")
+	buf.WriteString("</head>\n")
+	buf.WriteString("<body>\n")
+	writeSVG(&buf, newDiagram(machine, fsm, options))
+	buf.WriteString("</body>\n")
+	buf.WriteString("</html>\n")
+
+	return buf.String()
+}
+
+func writeSVG(buf *bytes.Buffer, d *diagram) {
+	layout := newSVGLayout(d)
+
+	legendHeight := float64(len(d.legend) * (svgFontSize + 4))
+	width, height := layout.width, layout.height+legendHeight
+
+	buf.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s" font-family="sans-serif" font-size="%d">`,
+		svgNumber(width), svgNumber(height), svgNumber(width), svgNumber(height), svgFontSize))
+	buf.WriteString("\n")
+
+	markers := writeSVGMarkers(buf, d.edges)
+	writeSVGTransitions(buf, d, layout, markers)
+	writeSVGStates(buf, d, layout)
+
+	for i, line := range d.legend {
+		buf.WriteString(fmt.Sprintf(`  <text class="legend" x="%d" y="%s">%s</text>`,
+			svgMargin, svgNumber(layout.height+float64((i+1)*(svgFontSize+4))-svgMargin/2), escapeXML(line)))
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("</svg>\n")
+}
+
+// newSVGLayout places the states of d in layers following the transitions,
+// ordering the states of each layer to reduce crossings.
+func newSVGLayout(d *diagram) *svgLayout {
+	layout := &svgLayout{nodes: make(map[string]*svgNode)}
+
+	layers := svgLayers(d)
+	svgOrderLayers(d, layers)
+
+	vertical := d.config.direction == "" || d.config.direction == TopToBottom || d.config.direction == BottomToTop
+
+	// main is the axis along the layers, cross the axis along a layer
+	var mainOffset, crossExtent float64
+	mains := make([]float64, len(layers))
+	for i, layer := range layers {
+		var mainSize, crossSize float64
+		for _, state := range layer {
+			node := &svgNode{
+				state:  state,
+				layer:  i,
+				width:  math.Max(80, float64(utf8.RuneCountInString(state.label)*svgCharWidth+svgStatePadding)),
+				height: svgStateHeight,
+			}
+			layout.nodes[state.name] = node
+
+			nodeMain, nodeCross := node.height, node.width
+			if !vertical {
+				nodeMain, nodeCross = node.width, node.height
+			}
+			mainSize = math.Max(mainSize, nodeMain)
+			crossSize += nodeCross
+		}
+		crossSize += float64(len(layer)-1) * svgStateGap
+
+		mains[i] = mainOffset + mainSize/2
+		mainOffset += mainSize + svgLayerGap
+		crossExtent = math.Max(crossExtent, crossSize)
+	}
+	mainExtent := math.Max(0, mainOffset-svgLayerGap)
+
+	for i, layer := range layers {
+		var crossSize float64
+		for _, state := range layer {
+			node := layout.nodes[state.name]
+			if vertical {
+				crossSize += node.width
+			} else {
+				crossSize += node.height
+			}
+		}
+		crossSize += float64(len(layer)-1) * svgStateGap
+
+		cross := (crossExtent - crossSize) / 2
+		for order, state := range layer {
+			node := layout.nodes[state.name]
+			node.order = order
+
+			main := mains[i]
+			if d.config.direction == BottomToTop || d.config.direction == RightToLeft {
+				main = mainExtent - main
+			}
+
+			if vertical {
+				node.position = svgPoint{svgMargin + cross + node.width/2, svgMargin + main}
+				cross += node.width + svgStateGap
+			} else {
+				node.position = svgPoint{svgMargin + main, svgMargin + svgLoopSize + cross + node.height/2}
+				cross += node.height + svgStateGap
+			}
+		}
+	}
+
+	// leave room for the self loops and the labels of the transitions
+	if vertical {
+		layout.width = crossExtent + 2*svgMargin + 2*svgLoopSize + svgCharWidth*svgLongestLabel(d.edges)
+		layout.height = mainExtent + 2*svgMargin
+	} else {
+		layout.width = mainExtent + 2*svgMargin + svgCharWidth*svgLongestLabel(d.edges)
+		layout.height = crossExtent + 2*svgMargin + 2*svgLoopSize
+	}
+
+	return layout
+}
+
+// svgLayers assigns the states of d to layers by their distance from the
+// states without incoming transitions, or from the current state if every
+// state has one.
+func svgLayers(d *diagram) [][]diagramState {
+	incoming := make(map[string]bool)
+	next := make(map[string][]string)
+	for _, edge := range d.edges {
+		if edge.source != edge.destination {
+			incoming[edge.destination] = true
+			next[edge.source] = append(next[edge.source], edge.destination)
+		}
+	}
+
+	states := make(map[string]diagramState)
+	var roots []string
+	for _, state := range d.states {
+		states[state.name] = state
+		if !incoming[state.name] {
+			roots = append(roots, state.name)
+		}
+	}
+	if _, ok := states[d.current]; ok && len(roots) == 0 {
+		roots = append(roots, d.current)
+	}
+
+	layerOf := make(map[string]int)
+	var layers [][]diagramState
+
+	visit := func(root string) {
+		layerOf[root] = 0
+		queue := []string{root}
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+
+			layer := layerOf[name]
+			for len(layers) <= layer {
+				layers = append(layers, nil)
+			}
+			layers[layer] = append(layers[layer], states[name])
+
+			for _, destination := range next[name] {
+				if _, ok := layerOf[destination]; !ok {
+					layerOf[destination] = layer + 1
+					queue = append(queue, destination)
+				}
+			}
+		}
+	}
+
+	for _, root := range roots {
+		if _, ok := layerOf[root]; !ok {
+			visit(root)
+		}
+	}
+	for _, state := range d.states {
+		if _, ok := layerOf[state.name]; !ok {
+			visit(state.name)
+		}
+	}
+
+	return layers
+}
+
+// svgOrderLayers sorts the states of each layer by the average position of
+// their predecessors in the previous layers.
+func svgOrderLayers(d *diagram, layers [][]diagramState) {
+	if len(layers) == 0 {
+		return
+	}
+
+	position := make(map[string]float64)
+	for order, state := range layers[0] {
+		position[state.name] = float64(order)
+	}
+
+	for i := 1; i < len(layers); i++ {
+		barycenters := make(map[string]float64, len(layers[i]))
+		for order, state := range layers[i] {
+			var sum, count float64
+			for _, edge := range d.edges {
+				if p, ok := position[edge.source]; ok && edge.destination == state.name && edge.source != state.name {
+					sum += p
+					count++
+				}
+			}
+
+			barycenters[state.name] = float64(order)
+			if count > 0 {
+				barycenters[state.name] = sum / count
+			}
+		}
+
+		sort.SliceStable(layers[i], func(a, b int) bool {
+			return barycenters[layers[i][a].name] < barycenters[layers[i][b].name]
+		})
+
+		for order, state := range layers[i] {
+			position[state.name] = float64(order)
+		}
+	}
+}
+
+// writeSVGMarkers defines an arrowhead per transition color and returns
+// their IDs by color.
+func writeSVGMarkers(buf *bytes.Buffer, edges []diagramEdge) map[string]string {
+	markers := make(map[string]string)
+
+	buf.WriteString("  <defs>\n")
+	for _, edge := range edges {
+		color := svgEdgeColorOf(edge)
+		if _, ok := markers[color]; ok {
+			continue
+		}
+
+		markers[color] = fmt.Sprintf("arrow%d", len(markers))
+		buf.WriteString(fmt.Sprintf(`    <marker id="%s" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" markerUnits="userSpaceOnUse" orient="auto"><path d="M 0 0 L 10 5 L 0 10 z" fill="%s"/></marker>`,
+			markers[color], escapeXML(color)))
+		buf.WriteString("\n")
+	}
+	buf.WriteString("  </defs>\n")
+
+	return markers
+}
+
+func writeSVGTransitions(buf *bytes.Buffer, d *diagram, layout *svgLayout, markers map[string]string) {
+	// parallel groups the transitions joining the same states, in either direction
+	parallel := make(map[[2]string][]int)
+	loops := make(map[string]int)
+	for i, edge := range d.edges {
+		parallel[svgPair(edge)] = append(parallel[svgPair(edge)], i)
+	}
+
+	vertical := d.config.direction == "" || d.config.direction == TopToBottom || d.config.direction == BottomToTop
+
+	for i, edge := range d.edges {
+		source, destination := layout.nodes[edge.source], layout.nodes[edge.destination]
+
+		var path string
+		var label svgPoint
+		anchor := "middle"
+
+		if edge.source == edge.destination {
+			size := svgLoopSize * (1 + 0.5*float64(loops[edge.source]))
+			loops[edge.source]++
+
+			if vertical {
+				right := source.position.x + source.width/2
+				y := source.position.y
+				path = fmt.Sprintf("M %s %s C %s %s %s %s %s %s",
+					svgNumber(right), svgNumber(y-8), svgNumber(right+size), svgNumber(y-size/2),
+					svgNumber(right+size), svgNumber(y+size/2), svgNumber(right), svgNumber(y+8))
+				label, anchor = svgPoint{right + 0.75*size + 4, y + svgFontSize/3}, "start"
+			} else {
+				top := source.position.y - source.height/2
+				x := source.position.x
+				path = fmt.Sprintf("M %s %s C %s %s %s %s %s %s",
+					svgNumber(x-8), svgNumber(top), svgNumber(x-size/2), svgNumber(top-size),
+					svgNumber(x+size/2), svgNumber(top-size), svgNumber(x+8), svgNumber(top))
+				label = svgPoint{x, top - 0.75*size - 4}
+			}
+		} else {
+			group := parallel[svgPair(edge)]
+			index := sort.SearchInts(group, i)
+			offset := (float64(index) - float64(len(group)-1)/2) * svgParallelOffset
+			// the offsets are taken in the direction of the pair so that
+			// opposite transitions do not overlap
+			if edge.source != svgPair(edge)[0] {
+				offset = -offset
+			}
+
+			dx, dy := destination.position.x-source.position.x, destination.position.y-source.position.y
+			length := math.Hypot(dx, dy)
+			control := svgPoint{
+				(source.position.x+destination.position.x)/2 - dy/length*offset*2,
+				(source.position.y+destination.position.y)/2 + dx/length*offset*2,
+			}
+
+			start, end := source.border(control), destination.border(control)
+			path = fmt.Sprintf("M %s %s Q %s %s %s %s",
+				svgNumber(start.x), svgNumber(start.y), svgNumber(control.x), svgNumber(control.y), svgNumber(end.x), svgNumber(end.y))
+			label = svgPoint{
+				0.25*start.x + 0.5*control.x + 0.25*end.x,
+				0.25*start.y + 0.5*control.y + 0.25*end.y - 4,
+			}
+		}
+
+		width := edge.width
+		if width == 0 {
+			width = 1
+		}
+
+		color := svgEdgeColorOf(edge)
+		buf.WriteString(fmt.Sprintf(`  <g class="transition" data-event="%s" data-src="%s" data-dst="%s">`,
+			escapeXML(edge.name), escapeXML(edge.source), escapeXML(edge.destination)))
+		buf.WriteString("\n")
+		if tooltip := svgTransitionTooltip(d.machine, edge); tooltip != "" {
+			buf.WriteString(fmt.Sprintf("    <title>%s</title>\n", escapeXML(tooltip)))
+		}
+		buf.WriteString(fmt.Sprintf(`    <path d="%s" fill="none" stroke="%s" stroke-width="%d" marker-end="url(#%s)"/>`,
+			path, escapeXML(color), width, markers[color]))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf(`    <text x="%s" y="%s" text-anchor="%s" stroke="#FFFFFF" stroke-width="4" paint-order="stroke">%s</text>`,
+			svgNumber(label.x), svgNumber(label.y), anchor, escapeXML(edge.label)))
+		buf.WriteString("\n")
+		buf.WriteString("  </g>\n")
+	}
+}
+
+func writeSVGStates(buf *bytes.Buffer, d *diagram, layout *svgLayout) {
+	for _, state := range d.states {
+		node := layout.nodes[state.name]
+
+		fill := d.fillColor(state, highlightingColor)
+		if fill == "" {
+			fill = svgStateColor
+		}
+		stroke := state.style.BorderColor
+		if stroke == "" {
+			stroke = svgEdgeColor
+		}
+		text := state.style.TextColor
+		if text == "" {
+			text = "#000000"
+		}
+
+		class := "state"
+		if state.current {
+			class += " current"
+		}
+
+		buf.WriteString(fmt.Sprintf(`  <g class="%s" data-state="%s">`, class, escapeXML(state.name)))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf("    <title>%s</title>\n", escapeXML(svgStateTooltip(d.machine, state.name))))
+		buf.WriteString(fmt.Sprintf(`    <rect x="%s" y="%s" width="%s" height="%s" rx="8" ry="8" fill="%s" stroke="%s" stroke-width="1"/>`,
+			svgNumber(node.position.x-node.width/2), svgNumber(node.position.y-node.height/2), svgNumber(node.width), svgNumber(node.height),
+			escapeXML(fill), escapeXML(stroke)))
+		buf.WriteString("\n")
+		buf.WriteString(fmt.Sprintf(`    <text x="%s" y="%s" text-anchor="middle" fill="%s">%s</text>`,
+			svgNumber(node.position.x), svgNumber(node.position.y+svgFontSize/3), escapeXML(text), escapeXML(state.label)))
+		buf.WriteString("\n")
+		buf.WriteString("  </g>\n")
+	}
+}
+
+// border returns the point of the border of the node in the direction of p.
+func (n *svgNode) border(p svgPoint) svgPoint {
+	dx, dy := p.x-n.position.x, p.y-n.position.y
+	if dx == 0 && dy == 0 {
+		return n.position
+	}
+
+	scale := math.Inf(1)
+	if dx != 0 {
+		scale = math.Min(scale, n.width/2/math.Abs(dx))
+	}
+	if dy != 0 {
+		scale = math.Min(scale, n.height/2/math.Abs(dy))
+	}
+
+	return svgPoint{n.position.x + dx*scale, n.position.y + dy*scale}
+}
+
+// svgStateTooltip lists the callbacks which may run when entering or leaving
+// state, including the ones of the transitions leaving it.
+func svgStateTooltip(machine *Machine, state string) string {
+	var callbacks []string
+
+	hooks := []Hook{{HookEnterState, state}, {HookEnterState, ""}, {HookLeaveState, state}, {HookLeaveState, ""}}
+	for _, k := range getSortedTransitionKeys(machine.transitions) {
+		if k.Src == state {
+			hooks = append(hooks, Hook{HookBeforeTransition, k.Event}, Hook{HookAfterTransition, k.Event})
+		}
+	}
+	hooks = append(hooks, Hook{HookBeforeTransition, ""}, Hook{HookAfterTransition, ""})
+
+	for _, hook := range hooks {
+		if machine.hasCallback(hook) {
+			callbacks = append(callbacks, hook.String())
+		}
+	}
+
+	if len(callbacks) == 0 {
+		return state + "\nno callbacks"
+	}
+
+	return state + "\ncallbacks: " + strings.Join(callbacks, ", ")
+}
+
+// svgTransitionTooltip lists the callbacks registered for the event of edge.
+func svgTransitionTooltip(machine *Machine, edge diagramEdge) string {
+	var callbacks []string
+	for _, hook := range []Hook{{HookBeforeTransition, edge.name}, {HookAfterTransition, edge.name}} {
+		if machine.hasCallback(hook) {
+			callbacks = append(callbacks, hook.String())
+		}
+	}
+
+	if len(callbacks) == 0 {
+		return ""
+	}
+
+	return edge.name + "\ncallbacks: " + strings.Join(callbacks, ", ")
+}
+
+// svgPair returns the states joined by edge, sorted.
+func svgPair(edge diagramEdge) [2]string {
+	if edge.source < edge.destination {
+		return [2]string{edge.source, edge.destination}
+	}
+
+	return [2]string{edge.destination, edge.source}
+}
+
+func svgEdgeColorOf(edge diagramEdge) string {
+	if edge.color != "" {
+		return edge.color
+	}
+
+	return svgEdgeColor
+}
+
+// svgLongestLabel returns the length of the longest transition label.
+func svgLongestLabel(edges []diagramEdge) float64 {
+	var longest int
+	for _, edge := range edges {
+		longest = max(longest, utf8.RuneCountInString(edge.label))
+	}
+
+	return float64(longest)
+}
+
+// svgNumber formats a coordinate with at most one decimal.
+func svgNumber(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// parseSVG reads the transitions back from the data attributes of an SVG
+// visualization, failing if it is not well-formed XML.
+func parseSVG(t *testing.T, output string) []visualizedEdge {
+	var edges []visualizedEdge
+
+	decoder := xml.NewDecoder(strings.NewReader(output))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Errorf("svg output is not well-formed: %s", err)
+			break
+		}
+
+		if element, ok := token.(xml.StartElement); ok && element.Name.Local == "g" {
+			attributes := make(map[string]string)
+			for _, attribute := range element.Attr {
+				attributes[attribute.Name.Local] = attribute.Value
+			}
+
+			if attributes["class"] == "transition" {
+				edges = append(edges, visualizedEdge{attributes["data-src"], attributes["data-event"], attributes["data-dst"]})
+			}
+		}
+	}
+
+	return edges
+}
+
+// xmlSafe replaces the characters which are not allowed in XML like escapeXML.
+func xmlSafe(s string) string {
+	return strings.Map(func(r rune) rune {
+		if !isXMLRune(r) {
+			return unicode.ReplacementChar
+		}
+
+		return r
+	}, s)
+}
+
+func TestVisualizeAsSVG(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "close", Sources: []string{"open"}, Destination: "closed"},
+			{Name: "part-open", Sources: []string{"closed"}, Destination: "intermediate"},
+			{Name: "retry", Sources: []string{"intermediate"}, Destination: "intermediate"},
+		},
+		map[string]Callback{
+			"enter_open":   func(*Transition) {},
+			"before_close": func(*Transition) {},
+		},
+	)
+
+	got := VisualizeAsSVG(machineUnderTest, machineUnderTest.NewInstance("closed"))
+
+	edges := parseSVG(t, got)
+	if len(edges) != 4 {
+		t.Errorf("expected 4 transitions, got %v", edges)
+	}
+
+	for _, part := range []string{
+		`<g class="state current" data-state="closed">`,
+		`fill="#00AA00"`,
+		"<title>open&#10;callbacks: enter_open, before_close</title>",
+		"<title>close&#10;callbacks: before_close</title>",
+		`<path d="M 270 156 C 310 144 310 184 270 172"`,
+	} {
+		if !strings.Contains(got, part) {
+			t.Errorf("expected svg to contain %q, got \n%s", part, got)
+		}
+	}
+
+	// closed is the only state without incoming transitions, it is alone in the first layer
+	if !strings.Contains(got, `<rect x="105" y="20" width="80" height="36"`) {
+		t.Errorf("expected closed to be laid out in the first layer, got \n%s", got)
+	}
+}
+
+func TestVisualizeAsHTML(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{{Name: "open", Sources: []string{"closed"}, Destination: "open"}},
+		map[string]Callback{},
+		WithName("door <main>"),
+	)
+
+	got, err := VisualizeWithType(machineUnderTest, machineUnderTest.NewInstance("closed"), HTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(got, "<!DOCTYPE html>\n") || !strings.HasSuffix(got, "</body>\n</html>\n") {
+		t.Errorf("expected a complete html page, got \n%s", got)
+	}
+	if !strings.Contains(got, "<title>door &#60;main&#62;</title>") {
+		t.Errorf("expected the escaped machine name as title, got \n%s", got)
+	}
+	if !strings.Contains(got, `<svg xmlns="http://www.w3.org/2000/svg"`) {
+		t.Errorf("expected an embedded svg, got \n%s", got)
+	}
+}
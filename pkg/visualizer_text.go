@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextCharset the characters used to draw a text visualization
+type TextCharset string
+
+const (
+	// ASCIICharset draws with plain ASCII characters, suitable for any terminal or log
+	ASCIICharset TextCharset = "ascii"
+	// UnicodeCharset draws with Unicode box-drawing characters
+	UnicodeCharset TextCharset = "unicode"
+)
+
+// textBox holds the characters drawing the border of a state.
+type textBox struct {
+	topLeft, topRight, bottomLeft, bottomRight, horizontal, vertical string
+}
+
+// textGlyphs holds the characters of a charset.
+type textGlyphs struct {
+	// state and current draw the states, current being used for the current state.
+	state, current textBox
+	// edge, lastEdge and arrow draw the transitions leaving a state, boldArrow the highlighted ones.
+	edge, lastEdge, arrow, boldArrow string
+}
+
+var textCharsets = map[TextCharset]textGlyphs{
+	ASCIICharset: {
+		state:     textBox{"+", "+", "+", "+", "-", "|"},
+		current:   textBox{"#", "#", "#", "#", "=", "#"},
+		edge:      "|--",
+		lastEdge:  "`--",
+		arrow:     "-->",
+		boldArrow: "==>",
+	},
+	UnicodeCharset: {
+		state:     textBox{"┌", "┐", "└", "┘", "─", "│"},
+		current:   textBox{"╔", "╗", "╚", "╝", "═", "║"},
+		edge:      "├─",
+		lastEdge:  "└─",
+		arrow:     "─▶",
+		boldArrow: "━▶",
+	},
+}
+
+// VisualizeAsTextWithCharset outputs a visualization of a FSM as text drawn with the given charset,
+// with each state in a box followed by its outgoing transitions. The current state has a double border.
+func VisualizeAsTextWithCharset(machine *Machine, fsm *Instance, charset TextCharset, options ...VisualizeOption) (string, error) {
+	glyphs, ok := textCharsets[charset]
+	if !ok {
+		return "", fmt.Errorf("unknown TextCharset: %s", charset)
+	}
+
+	var buf bytes.Buffer
+
+	d := newDiagram(machine, fsm, options)
+
+	for _, state := range d.states {
+		writeTextState(&buf, glyphs, state)
+		writeTextTransitions(&buf, glyphs, state.name, d.edges)
+	}
+
+	return buf.String(), nil
+}
+
+func writeTextState(buf *bytes.Buffer, glyphs textGlyphs, state diagramState) {
+	box := glyphs.state
+	if state.current {
+		box = glyphs.current
+	}
+
+	label := escapeText(state.label)
+	line := strings.Repeat(box.horizontal, utf8.RuneCountInString(label)+2)
+
+	buf.WriteString(box.topLeft + line + box.topRight + "\n")
+	buf.WriteString(box.vertical + " " + label + " " + box.vertical + "\n")
+	buf.WriteString(box.bottomLeft + line + box.bottomRight + "\n")
+}
+
+func writeTextTransitions(buf *bytes.Buffer, glyphs textGlyphs, state string, edges []diagramEdge) {
+	var leaving []diagramEdge
+	for _, edge := range edges {
+		if edge.source == state {
+			leaving = append(leaving, edge)
+		}
+	}
+
+	for i, edge := range leaving {
+		line := glyphs.edge
+		if i == len(leaving)-1 {
+			line = glyphs.lastEdge
+		}
+
+		arrow := glyphs.arrow
+		if edge.color != "" {
+			arrow = glyphs.boldArrow
+		}
+
+		buf.WriteString(fmt.Sprintf("  %s %s %s %s", line, escapeText(edge.label), arrow, escapeText(edge.destination)))
+		buf.WriteString("\n")
+	}
+
+	buf.WriteString("\n")
+}
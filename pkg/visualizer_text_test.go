@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"testing"
+)
+
+func TestTextOutput(t *testing.T) {
+	machineUnderTest := NewMachine(
+		[]TransitionDesc{
+			{Name: "open", Sources: []string{"closed"}, Destination: "open"},
+			{Name: "part-open", Sources: []string{"closed"}, Destination: "intermediate"},
+			{Name: "close", Sources: []string{"open", "intermediate"}, Destination: "closed"},
+		},
+		map[string]Callback{},
+	)
+
+	i := machineUnderTest.NewInstance("closed")
+
+	tests := []struct {
+		visualizeType VisualizeType
+		wanted        string
+	}{
+		{
+			visualizeType: ASCII,
+			wanted: `#========#
+# closed #
+#========#
+  |-- open --> open
+  ` + "`" + `-- part-open --> intermediate
+
++--------------+
+| intermediate |
++--------------+
+  ` + "`" + `-- close --> closed
+
++------+
+| open |
++------+
+  ` + "`" + `-- close --> closed
+
+`,
+		},
+		{
+			visualizeType: UNICODE,
+			wanted: `╔════════╗
+║ closed ║
+╚════════╝
+  ├─ open ─▶ open
+  └─ part-open ─▶ intermediate
+
+┌──────────────┐
+│ intermediate │
+└──────────────┘
+  └─ close ─▶ closed
+
+┌──────┐
+│ open │
+└──────┘
+  └─ close ─▶ closed
+
+`,
+		},
+	}
+
+	for _, test := range tests {
+		got, err := VisualizeWithType(machineUnderTest, i, test.visualizeType)
+		if err != nil {
+			t.Errorf("got error for visualizing with type %s: %s", test.visualizeType, err)
+		}
+		if got != test.wanted {
+			t.Errorf("build %s graph failed. \nwanted \n%s\nand got \n%s\n", test.visualizeType, test.wanted, got)
+		}
+	}
+
+	if _, err := VisualizeAsTextWithCharset(machineUnderTest, i, "braille"); err == nil {
+		t.Error("expected error for unknown charset")
+	}
+}